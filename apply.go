@@ -0,0 +1,612 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// devNull is the name a unified diff gives the missing side of a created or
+// deleted file.
+const devNull = "/dev/null"
+
+// filePatch is the part of a unified diff that changes one file.
+type filePatch struct {
+	oldName, newName string
+	rename, copy     bool
+	hunks            []patchHunk
+}
+
+// patchHunk is one "@@" section of a file patch.
+type patchHunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []hunkLine
+	raw                []string // as written in the patch, for .rej files
+}
+
+// hunkLine is a context (' '), deleted ('-') or inserted ('+') line. text
+// includes the line's terminator, which is empty for a last line marked
+// "\ No newline at end of file".
+type hunkLine struct {
+	op   byte
+	text string
+}
+
+// parsePatch splits a unified diff into per-file patches. Lines between
+// files that are not part of a patch, such as "Only in" notices, a lone
+// "--- path" for a file present on one side only, or "Binary files"
+// messages, are skipped.
+func parsePatch(data string) ([]filePatch, error) {
+	lines := strings.SplitAfter(data, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i := range lines {
+		lines[i] = strings.TrimSuffix(lines[i], "\n")
+	}
+
+	var patches []filePatch
+	var pending filePatch // extended headers seen before "---"
+	flush := func() {
+		// A rename or copy with identical content has no "---" line.
+		if pending.newName != "" {
+			patches = append(patches, pending)
+		}
+		pending = filePatch{}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "similarity index "):
+			flush()
+		case strings.HasPrefix(line, "rename from "):
+			pending.oldName, pending.rename = strings.TrimPrefix(line, "rename from "), true
+		case strings.HasPrefix(line, "rename to "):
+			pending.newName = strings.TrimPrefix(line, "rename to ")
+		case strings.HasPrefix(line, "copy from "):
+			pending.oldName, pending.copy = strings.TrimPrefix(line, "copy from "), true
+		case strings.HasPrefix(line, "copy to "):
+			pending.newName = strings.TrimPrefix(line, "copy to ")
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			oldName := patchName(line[len("--- "):])
+			newName := patchName(lines[i+1][len("+++ "):])
+			// The rename headers name the files without any a/ and b/
+			// prefixes; a pair that does not match starts another file.
+			if !strings.HasSuffix(oldName, pending.oldName) || !strings.HasSuffix(newName, pending.newName) {
+				flush()
+			}
+			patch := pending
+			pending = filePatch{}
+			patch.oldName, patch.newName = oldName, newName
+			i += 2
+			for i < len(lines) && strings.HasPrefix(lines[i], "@@ ") {
+				hunk, next, err := parseHunk(lines, i)
+				if err != nil {
+					return nil, err
+				}
+				patch.hunks = append(patch.hunks, hunk)
+				i = next
+			}
+			i--
+			// Headers with no hunks, as printed for files that exist in
+			// only one directory, leave nothing to apply.
+			if len(patch.hunks) > 0 || patch.rename || patch.copy {
+				patches = append(patches, patch)
+			}
+		}
+	}
+	flush()
+	return patches, nil
+}
+
+// patchName returns the file name from a "---" or "+++" line, without the
+// timestamp that GNU diff separates from it with a tab.
+func patchName(s string) string {
+	if tab := strings.IndexByte(s, '\t'); tab >= 0 {
+		s = s[:tab]
+	}
+	return s
+}
+
+// parseHunk parses the hunk whose header is lines[start] and returns it with
+// the index of the line that follows it.
+func parseHunk(lines []string, start int) (patchHunk, int, error) {
+	var hunk patchHunk
+	header := lines[start]
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return hunk, 0, fmt.Errorf("line %d: malformed hunk header %q", start+1, header)
+	}
+	var err1, err2 error
+	hunk.oldStart, hunk.oldCount, err1 = parseRange(fields[1][1:])
+	hunk.newStart, hunk.newCount, err2 = parseRange(fields[2][1:])
+	if err1 != nil || err2 != nil {
+		return hunk, 0, fmt.Errorf("line %d: malformed hunk header %q", start+1, header)
+	}
+	hunk.raw = append(hunk.raw, header)
+
+	oldSeen, newSeen := 0, 0
+	i := start + 1
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "\\") {
+			if len(hunk.lines) == 0 {
+				return hunk, 0, fmt.Errorf("line %d: unexpected %q", i+1, line)
+			}
+			last := &hunk.lines[len(hunk.lines)-1]
+			last.text = strings.TrimSuffix(last.text, "\n")
+			hunk.raw = append(hunk.raw, line)
+			continue
+		}
+		if oldSeen == hunk.oldCount && newSeen == hunk.newCount {
+			break
+		}
+
+		// Some editors strip the space from an empty context line.
+		if line == "" {
+			line = " "
+		}
+		op := line[0]
+		switch op {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		default:
+			return hunk, 0, fmt.Errorf("line %d: unexpected %q in hunk", i+1, line)
+		}
+		if oldSeen > hunk.oldCount || newSeen > hunk.newCount {
+			return hunk, 0, fmt.Errorf("line %d: hunk is longer than its header says", i+1)
+		}
+		hunk.lines = append(hunk.lines, hunkLine{op, line[1:] + "\n"})
+		hunk.raw = append(hunk.raw, lines[i])
+	}
+	if oldSeen != hunk.oldCount || newSeen != hunk.newCount {
+		return hunk, 0, fmt.Errorf("line %d: hunk is shorter than its header says", i+1)
+	}
+	return hunk, i, nil
+}
+
+// parseRange parses "start,count" or "start", which means a count of one.
+func parseRange(s string) (int, int, error) {
+	start, count, found := strings.Cut(s, ",")
+	n, err := strconv.Atoi(start)
+	if err != nil || n < 0 {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	if !found {
+		return n, 1, nil
+	}
+	c, err := strconv.Atoi(count)
+	if err != nil || c < 0 {
+		return 0, 0, fmt.Errorf("invalid range %q", s)
+	}
+	return n, c, nil
+}
+
+// reverse returns the patch that undoes p.
+func (p filePatch) reverse() filePatch {
+	r := p
+	r.oldName, r.newName = p.newName, p.oldName
+	r.hunks = make([]patchHunk, len(p.hunks))
+	for i, h := range p.hunks {
+		rh := h
+		rh.oldStart, rh.oldCount, rh.newStart, rh.newCount = h.newStart, h.newCount, h.oldStart, h.oldCount
+		rh.lines = make([]hunkLine, len(h.lines))
+		for j, line := range h.lines {
+			switch line.op {
+			case '-':
+				line.op = '+'
+			case '+':
+				line.op = '-'
+			}
+			rh.lines[j] = line
+		}
+		r.hunks[i] = rh
+	}
+	return r
+}
+
+// side returns the hunk's lines as they read before (ops ' ' and '-') or
+// after (' ' and '+') the change.
+func (h patchHunk) side(after bool) []string {
+	drop := byte('+')
+	if after {
+		drop = '-'
+	}
+	var lines []string
+	for _, line := range h.lines {
+		if line.op != drop {
+			lines = append(lines, line.text)
+		}
+	}
+	return lines
+}
+
+// trimContext drops up to fuzz lines of context from each end of the hunk.
+func (h patchHunk) trimContext(fuzz int) (patchHunk, int) {
+	leading := 0
+	for leading < len(h.lines) && leading < fuzz && h.lines[leading].op == ' ' {
+		leading++
+	}
+	trailing := 0
+	for trailing < len(h.lines)-leading && trailing < fuzz && h.lines[len(h.lines)-1-trailing].op == ' ' {
+		trailing++
+	}
+	h.lines = h.lines[leading : len(h.lines)-trailing]
+	return h, leading
+}
+
+// hunkResult records where a hunk was applied, or that it failed.
+type hunkResult struct {
+	applied bool
+	line    int // 1-based line in the patched file where the hunk starts
+	offset  int // lines from where the header said it would be
+	fuzz    int
+}
+
+// applyHunks applies the hunks in order to lines, each of which includes
+// its terminator. A hunk that does not match where its header says is
+// searched for up to maxOffset lines away (any distance if negative),
+// nearest first, and then matched again with up to fuzz lines of context
+// ignored at each end.
+func applyHunks(lines []string, hunks []patchHunk, fuzz, maxOffset int) ([]string, []hunkResult) {
+	result := append([]string(nil), lines...)
+	results := make([]hunkResult, len(hunks))
+	delta := 0   // lines added by the hunks applied so far
+	lastOff := 0 // offset of the previous hunk, which later ones likely share
+	minPos := 0  // hunks may not overlap ones already applied
+
+	for i, hunk := range hunks {
+		expected := hunk.oldStart - 1
+		if hunk.oldCount == 0 {
+			expected = hunk.oldStart
+		}
+		expected += delta
+
+		prevLen := -1
+		for f := 0; f <= fuzz && !results[i].applied; f++ {
+			trimmed, leading := hunk.trimContext(f)
+			if len(trimmed.lines) == prevLen {
+				break // no more context to ignore
+			}
+			prevLen = len(trimmed.lines)
+			old := trimmed.side(false)
+			pos, ok := findLines(result, old, expected+lastOff+leading, minPos, maxOffset)
+			if !ok {
+				continue
+			}
+
+			// The ignored context may have run past the start of the file
+			start := max(pos-leading, 0)
+			replacement := trimmed.side(true)
+			result = append(result[:pos], append(replacement, result[pos+len(old):]...)...)
+			results[i] = hunkResult{applied: true, line: start + 1, offset: start - expected, fuzz: f}
+			lastOff = start - expected
+			delta += len(replacement) - len(old)
+			minPos = pos + len(replacement)
+		}
+	}
+	return result, results
+}
+
+// findLines looks for want in lines at or after minPos, starting at
+// expected and moving outwards up to maxOffset lines in either direction.
+func findLines(lines, want []string, expected, minPos, maxOffset int) (int, bool) {
+	last := len(lines) - len(want)
+	limit := max(expected-minPos, last-expected)
+	if maxOffset >= 0 {
+		limit = min(limit, maxOffset)
+	}
+	for off := 0; off <= limit; off++ {
+		for _, pos := range []int{expected + off, expected - off} {
+			if pos >= minPos && pos <= last && matchesAt(lines, want, pos) {
+				return pos, true
+			}
+			if off == 0 {
+				break
+			}
+		}
+	}
+	return 0, false
+}
+
+func matchesAt(lines, want []string, pos int) bool {
+	for i, line := range want {
+		if lines[pos+i] != line {
+			return false
+		}
+	}
+	return true
+}
+
+// applyOptions are the settings of "ddiff apply".
+type applyOptions struct {
+	strip     int
+	fuzz      int
+	maxOffset int
+	reverse   bool
+	dryRun    bool
+}
+
+// stripPath removes the first n slash-separated components of name.
+func stripPath(name string, n int) (string, bool) {
+	for ; n > 0; n-- {
+		slash := strings.IndexByte(name, '/')
+		if slash < 0 {
+			return "", false
+		}
+		name = name[slash+1:]
+	}
+	return name, name != ""
+}
+
+// targetPath resolves a patch file name inside dir, refusing names that
+// would reach outside it.
+func targetPath(dir, name string, strip int) (string, error) {
+	stripped, ok := stripPath(name, strip)
+	if !ok {
+		return "", fmt.Errorf("cannot strip %d components from %s", strip, name)
+	}
+	if filepath.IsAbs(stripped) || !filepath.IsLocal(filepath.FromSlash(stripped)) {
+		return "", fmt.Errorf("refusing to patch %s outside %s", stripped, dir)
+	}
+	return filepath.Join(dir, filepath.FromSlash(stripped)), nil
+}
+
+// applyFilePatch applies one file's patch, reporting progress the way GNU
+// patch does. If target is empty, the file is found under dir from the
+// names in the patch. It returns the number of hunks that failed.
+func applyFilePatch(patch filePatch, dir, target string, opts applyOptions) (int, error) {
+	if opts.reverse {
+		patch = patch.reverse()
+	}
+	creating := patch.oldName == devNull
+	deleting := patch.newName == devNull
+
+	source, dest := target, target
+	if target == "" {
+		var err error
+		if !creating {
+			if source, err = targetPath(dir, patch.oldName, opts.strip); err != nil {
+				return 0, err
+			}
+		}
+		if !deleting {
+			if dest, err = targetPath(dir, patch.newName, opts.strip); err != nil {
+				return 0, err
+			}
+		}
+		if creating {
+			source = dest
+		}
+		if deleting {
+			dest = source
+		}
+		// Without a rename header both names refer to the same file; use
+		// whichever exists, preferring the old one.
+		if !patch.rename && !patch.copy && source != dest {
+			if _, err := os.Stat(source); err != nil {
+				source = dest
+			} else {
+				dest = source
+			}
+		}
+	}
+
+	var lines []string
+	data, err := os.ReadFile(source)
+	switch {
+	case err == nil:
+		lines = strings.SplitAfter(string(data), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+	case creating && errors.Is(err, os.ErrNotExist):
+	default:
+		return 0, err
+	}
+
+	verb := "patching"
+	if opts.dryRun {
+		verb = "checking"
+	}
+	switch {
+	case patch.rename:
+		fmt.Printf("%s file %s (renamed from %s)\n", verb, dest, source)
+	case patch.copy:
+		fmt.Printf("%s file %s (copied from %s)\n", verb, dest, source)
+	default:
+		fmt.Printf("%s file %s\n", verb, dest)
+	}
+
+	patched, results := applyHunks(lines, patch.hunks, opts.fuzz, opts.maxOffset)
+	var rejects []patchHunk
+	for i, r := range results {
+		switch {
+		case !r.applied:
+			fmt.Printf("Hunk #%d FAILED at %d.\n", i+1, patch.hunks[i].oldStart)
+			rejects = append(rejects, patch.hunks[i])
+		case r.offset != 0 || r.fuzz != 0:
+			fmt.Printf("Hunk #%d succeeded at %d", i+1, r.line)
+			if r.fuzz != 0 {
+				fmt.Printf(" with fuzz %d", r.fuzz)
+			}
+			if r.offset != 0 {
+				fmt.Printf(" (offset %d %s)", r.offset, plural(abs(r.offset), "line", "lines"))
+			}
+			fmt.Println(".")
+		}
+	}
+
+	if len(rejects) > 0 {
+		rejectFile := dest + ".rej"
+		fmt.Printf("%d out of %d %s FAILED -- ", len(rejects), len(results), plural(len(results), "hunk", "hunks"))
+		if opts.dryRun {
+			fmt.Printf("not saving rejects to %s in a dry run\n", rejectFile)
+		} else {
+			fmt.Printf("saving rejects to file %s\n", rejectFile)
+			if err := writeRejects(rejectFile, patch, rejects); err != nil {
+				return len(rejects), err
+			}
+		}
+	}
+	if opts.dryRun {
+		return len(rejects), nil
+	}
+
+	content := strings.Join(patched, "")
+	if deleting && content == "" {
+		return len(rejects), os.Remove(source)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return len(rejects), err
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(source); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(dest, []byte(content), mode); err != nil {
+		return len(rejects), err
+	}
+	if patch.rename && dest != source {
+		return len(rejects), os.Remove(source)
+	}
+	return len(rejects), nil
+}
+
+// writeRejects saves the hunks that failed as a patch of their own, written
+// as they appeared in the input.
+func writeRejects(path string, patch filePatch, rejects []patchHunk) error {
+	var out strings.Builder
+	out.WriteString("--- " + patch.oldName + "\n")
+	out.WriteString("+++ " + patch.newName + "\n")
+	for _, hunk := range rejects {
+		for _, line := range hunk.raw {
+			out.WriteString(line + "\n")
+		}
+	}
+	return os.WriteFile(path, []byte(out.String()), 0644)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// attachShortValues rewrites -pN and -FN, the forms patch users type, as
+// -p=N and -F=N for the flag package, up to the first argument that is not
+// an option.
+func attachShortValues(args []string) []string {
+	args = append([]string(nil), args...)
+	for i, arg := range args {
+		if arg == "--" || !strings.HasPrefix(arg, "-") || arg == "-" {
+			break
+		}
+		if len(arg) > 2 && (arg[1] == 'p' || arg[1] == 'F') {
+			if _, err := strconv.Atoi(arg[2:]); err == nil {
+				args[i] = arg[:2] + "=" + arg[2:]
+			}
+		}
+	}
+	return args
+}
+
+// runApply implements "ddiff apply [options] [patch] [target]" and returns
+// the exit status: 0 if every hunk applied, 1 if any failed, 2 on trouble.
+func runApply(args []string) int {
+	flags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	var opts applyOptions
+	flags.IntVar(&opts.strip, "strip", 0, "Strip `N` leading path components from file names in the patch")
+	flags.IntVar(&opts.strip, "p", 0, "Strip `N` leading path components (short)")
+	flags.IntVar(&opts.fuzz, "fuzz", 2, "Ignore up to `N` lines of context at each end of a hunk that does not match")
+	flags.IntVar(&opts.fuzz, "F", 2, "Context lines that may be ignored (short)")
+	flags.IntVar(&opts.maxOffset, "max-offset", -1, "Search at most `N` lines away for a hunk that has moved (-1 for no limit)")
+	flags.BoolVar(&opts.reverse, "reverse", false, "Undo the patch instead of applying it")
+	flags.BoolVar(&opts.reverse, "R", false, "Undo the patch instead of applying it (short)")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "Check that the patch applies without changing any files")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s apply [options] [patch|-] [file|dir]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nReads the patch from standard input if it is omitted or -, and applies it\n")
+		fmt.Fprintf(os.Stderr, "to the given file or to the files it names under dir (default .).\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(attachShortValues(args)); err != nil {
+		return exitTrouble
+	}
+	if flags.NArg() > 2 || opts.strip < 0 || opts.fuzz < 0 {
+		flags.Usage()
+		return exitTrouble
+	}
+
+	var data []byte
+	var err error
+	if patchFile := flags.Arg(0); patchFile == "" || patchFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(patchFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading patch: %v\n", err)
+		return exitTrouble
+	}
+
+	patches, err := parsePatch(string(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing patch: %v\n", err)
+		return exitTrouble
+	}
+	if len(patches) == 0 {
+		fmt.Fprintf(os.Stderr, "No patch found in input\n")
+		return exitTrouble
+	}
+
+	dir, target := ".", ""
+	if flags.NArg() == 2 {
+		info, err := os.Stat(flags.Arg(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", flags.Arg(1), err)
+			return exitTrouble
+		}
+		if info.IsDir() {
+			dir = flags.Arg(1)
+		} else if len(patches) != 1 {
+			fmt.Fprintf(os.Stderr, "Patch changes %d files but only %s was given\n", len(patches), flags.Arg(1))
+			return exitTrouble
+		} else {
+			target = flags.Arg(1)
+		}
+	}
+
+	failed, troubles := 0, 0
+	for _, patch := range patches {
+		n, err := applyFilePatch(patch, dir, target, opts)
+		failed += n
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error applying patch: %v\n", err)
+			troubles++
+		}
+	}
+
+	if troubles > 0 {
+		return exitTrouble
+	}
+	if failed > 0 {
+		return exitDifferent
+	}
+	return exitSame
+}
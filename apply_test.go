@@ -0,0 +1,245 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParsePatch(t *testing.T) {
+	patch := strings.Join([]string{
+		"--- only_in_dir1.txt",
+		"+++ only_in_dir2.txt",
+		"--- a.txt\t2024-01-01 00:00:00",
+		"+++ a.txt\t2024-01-02 00:00:00",
+		"@@ -1,2 +1,2 @@",
+		" one",
+		"-two",
+		"\\ No newline at end of file",
+		"+2",
+		"\\ No newline at end of file",
+		"Binary files b.bin differ",
+		"similarity index 100%",
+		"rename from old.txt",
+		"rename to new.txt",
+		"similarity index 80%",
+		"copy from c.txt",
+		"copy to d.txt",
+		"--- c.txt",
+		"+++ d.txt",
+		"@@ -3,0 +4 @@",
+		"+added",
+		"",
+	}, "\n")
+
+	patches, err := parsePatch(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patches) != 3 {
+		t.Fatalf("expected 3 file patches, got %d: %+v", len(patches), patches)
+	}
+
+	a := patches[0]
+	if a.oldName != "a.txt" || a.newName != "a.txt" || len(a.hunks) != 1 {
+		t.Errorf("unexpected first patch %+v", a)
+	}
+	want := []hunkLine{{' ', "one\n"}, {'-', "two"}, {'+', "2"}}
+	if len(a.hunks[0].lines) != len(want) {
+		t.Fatalf("expected lines %q, got %q", want, a.hunks[0].lines)
+	}
+	for i := range want {
+		if a.hunks[0].lines[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], a.hunks[0].lines[i])
+		}
+	}
+
+	if r := patches[1]; !r.rename || r.oldName != "old.txt" || r.newName != "new.txt" || len(r.hunks) != 0 {
+		t.Errorf("unexpected rename %+v", r)
+	}
+	c := patches[2]
+	if !c.copy || c.oldName != "c.txt" || c.newName != "d.txt" || len(c.hunks) != 1 {
+		t.Errorf("unexpected copy %+v", c)
+	}
+	if h := c.hunks[0]; h.oldStart != 3 || h.oldCount != 0 || h.newStart != 4 || h.newCount != 1 {
+		t.Errorf("unexpected ranges %+v", h)
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	for _, patch := range []string{
+		"--- a\n+++ a\n@@ -1,2 +1,2 @@\n-x\n+y\n",
+		"--- a\n+++ a\n@@ -1 +1 @@\n+y\n+z\n-x\n",
+		"--- a\n+++ a\n@@ -x +1 @@\n-x\n+y\n",
+		"--- a\n+++ a\n@@ -1 +1 @@\n*x\n+y\n",
+	} {
+		if _, err := parsePatch(patch); err == nil {
+			t.Errorf("expected an error parsing %q", patch)
+		}
+	}
+}
+
+func TestApplyHunks(t *testing.T) {
+	hunk := patchHunk{
+		oldStart: 2, oldCount: 3, newStart: 2, newCount: 3,
+		lines: []hunkLine{{' ', "b\n"}, {'-', "c\n"}, {'+', "C\n"}, {' ', "d\n"}},
+	}
+	split := func(s string) []string {
+		lines := strings.SplitAfter(s, "\n")
+		return lines[:len(lines)-1]
+	}
+	cases := []struct {
+		name      string
+		lines     string
+		fuzz      int
+		maxOffset int
+		want      string
+		result    hunkResult
+	}{
+		{"exact", "a\nb\nc\nd\ne\n", 0, -1, "a\nb\nC\nd\ne\n", hunkResult{applied: true, line: 2}},
+		{"offset", "x\nx\nx\na\nb\nc\nd\n", 0, -1, "x\nx\nx\na\nb\nC\nd\n", hunkResult{applied: true, line: 5, offset: 3}},
+		{"offset too far", "x\nx\nx\na\nb\nc\nd\n", 0, 2, "x\nx\nx\na\nb\nc\nd\n", hunkResult{}},
+		{"fuzz", "a\nB\nc\nd\n", 1, -1, "a\nB\nC\nd\n", hunkResult{applied: true, line: 2, fuzz: 1}},
+		{"no fuzz", "a\nB\nc\nd\n", 0, -1, "a\nB\nc\nd\n", hunkResult{}},
+		{"fuzz past the start", "c\nd\n", 1, -1, "C\nd\n", hunkResult{applied: true, line: 1, offset: -1, fuzz: 1}},
+	}
+	for _, c := range cases {
+		got, results := applyHunks(split(c.lines), []patchHunk{hunk}, c.fuzz, c.maxOffset)
+		if strings.Join(got, "") != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, strings.Join(got, ""))
+		}
+		if results[0] != c.result {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.result, results[0])
+		}
+	}
+}
+
+func TestAttachShortValues(t *testing.T) {
+	got := attachShortValues([]string{"-p1", "-F0", "-R", "-p=2", "-pq", "fix.diff", "-p1"})
+	want := []string{"-p=1", "-F=0", "-R", "-p=2", "-pq", "fix.diff", "-p1"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestApplyRoundTrip(t *testing.T) {
+	binary, err := filepath.Abs("ddiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, context := range []int{0, 1, 3} {
+		for _, c := range roundTripCorpus() {
+			dir := t.TempDir()
+			diff := writeRoundTripFiles(t, dir, c, context)
+			if diff == "" {
+				continue
+			}
+
+			for _, reverse := range []bool{false, true} {
+				args := []string{"apply", "-p1"}
+				target, want := c.old, c.new
+				if reverse {
+					args = append(args, "-R")
+					target, want = c.new, c.old
+				}
+				work := filepath.Join(dir, "work")
+				if err := os.MkdirAll(work, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(work, "f"), []byte(target), 0644); err != nil {
+					t.Fatal(err)
+				}
+
+				cmd := exec.Command(binary, args...)
+				cmd.Dir = work
+				cmd.Stdin = strings.NewReader(diff)
+				if output, err := cmd.CombinedOutput(); err != nil {
+					t.Fatalf("%s (context %d, reverse %v): apply failed: %v\n%s\ndiff:\n%s", c.name, context, reverse, err, output, diff)
+				}
+				got, err := os.ReadFile(filepath.Join(work, "f"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("%s (context %d, reverse %v): applied file is %q, want %q\ndiff:\n%s", c.name, context, reverse, got, want, diff)
+				}
+			}
+		}
+	}
+}
+
+func TestCLIApplyTree(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	writeTree(t, old, map[string]string{
+		"a.txt":     "1\n2\n3\n4\n5\n6\n7\n8\n",
+		"sub/b.txt": "alpha\nbeta\ngamma\n",
+		"moved.txt": "one\ntwo\nthree\nfour\n",
+	})
+	new := filepath.Join(dir, "new")
+	writeTree(t, new, map[string]string{
+		"a.txt":          "1\n2\nthree\n4\n5\n6\n7\neight\n",
+		"sub/b.txt":      "alpha\nBETA\ngamma\n",
+		"renamed/to.txt": "one\ntwo\nthree\nfour\nfive\n",
+	})
+
+	patch, _ := exec.Command("./ddiff", "--color=false", "-M", old, new).Output()
+	if !strings.Contains(string(patch), "rename from moved.txt") {
+		t.Fatalf("expected a rename in the patch, got\n%s", patch)
+	}
+	patchFile := filepath.Join(dir, "patch.diff")
+	if err := os.WriteFile(patchFile, patch, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dry run changes nothing.
+	output, err := exec.Command("./ddiff", "apply", "--dry-run", patchFile, old).CombinedOutput()
+	if code := exitCode(err); code != exitSame {
+		t.Fatalf("dry run: expected exit status 0, got %d\n%s", code, output)
+	}
+	if !strings.Contains(string(output), "checking file") {
+		t.Errorf("dry run: expected checking messages, got\n%s", output)
+	}
+	if content, _ := os.ReadFile(filepath.Join(old, "a.txt")); string(content) != "1\n2\n3\n4\n5\n6\n7\n8\n" {
+		t.Errorf("dry run modified a.txt: %q", content)
+	}
+
+	output, err = exec.Command("./ddiff", "apply", patchFile, old).CombinedOutput()
+	if code := exitCode(err); code != exitSame {
+		t.Fatalf("apply: expected exit status 0, got %d\n%s", code, output)
+	}
+	if output, err := exec.Command("./ddiff", "--color=false", old, new).CombinedOutput(); err != nil {
+		t.Errorf("patched tree differs from the new tree: %v\n%s", err, output)
+	}
+
+	// A hunk whose context cannot be found is saved to a .rej file.
+	if err := os.WriteFile(filepath.Join(old, "sub", "b.txt"), []byte("unrelated\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	subPatch := "--- sub/b.txt\n+++ sub/b.txt\n@@ -1,3 +1,3 @@\n alpha\n-beta\n+BETA\n gamma\n"
+	cmd := exec.Command("./ddiff", "apply", "-", old)
+	cmd.Stdin = strings.NewReader(subPatch)
+	output, err = cmd.CombinedOutput()
+	if code := exitCode(err); code != exitDifferent {
+		t.Fatalf("failed hunk: expected exit status 1, got %d\n%s", code, output)
+	}
+	if !strings.Contains(string(output), "Hunk #1 FAILED at 1.") {
+		t.Errorf("expected a failure message, got\n%s", output)
+	}
+	rejects, err := os.ReadFile(filepath.Join(old, "sub", "b.txt.rej"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rejects) != subPatch {
+		t.Errorf("expected rejects\n%s\ngot\n%s", subPatch, rejects)
+	}
+
+	// Names may not reach outside the target directory.
+	cmd = exec.Command("./ddiff", "apply", "-", old)
+	cmd.Stdin = strings.NewReader("--- ../x\n+++ ../x\n@@ -1 +1 @@\n-a\n+b\n")
+	if code := exitCode(cmd.Run()); code != exitTrouble {
+		t.Errorf("escaping path: expected exit status 2, got %d", code)
+	}
+}
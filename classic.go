@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// changeRun is a maximal run of deleted and inserted lines within a group:
+// old lines [start1, end1) replaced by new lines [start2, end2).
+type changeRun struct {
+	start1, end1 int
+	start2, end2 int
+}
+
+// changeRuns collapses a group's edits into runs of changes, merging each
+// delete with the insert that follows it.
+func changeRuns(edits []Edit) []changeRun {
+	var runs []changeRun
+	for _, c := range changesFromEdits(edits) {
+		runs = append(runs, changeRun{c.base1, c.base2, c.side1, c.side2})
+	}
+	return runs
+}
+
+// classicLine renders line i of text with a two-character prefix, keeping
+// any carriage return, followed by the missing newline marker if it is the
+// unterminated last line of its file.
+func classicLine(prefix string, text textFile, i int) []string {
+	lines := []string{prefix + text.lines[i] + strings.TrimSuffix(text.eol(i), "\n")}
+	if text.missingNewline(i) && i == len(text.lines)-1 {
+		lines = append(lines, "\\ No newline at end of file")
+	}
+	return lines
+}
+
+// formatContextDiff renders hunk groups in the context format of diff -c:
+// each hunk lists its old lines and then its new lines, marking changed
+// lines with "!", deleted ones with "-" and inserted ones with "+". A side
+// with no changes of its own is given by its range alone.
+func formatContextDiff(file1, file2 string, text1, text2 textFile, groups [][]Edit, context int) []string {
+	if len(groups) == 0 {
+		return nil
+	}
+	result := []string{"*** " + file1, "--- " + file2}
+
+	for _, group := range groups {
+		start1, end1, start2, end2 := hunkBounds(len(text1.lines), len(text2.lines), group, context)
+		runs := changeRuns(group)
+
+		// Mark each changed line with how it changed.
+		marks1 := make(map[int]string)
+		marks2 := make(map[int]string)
+		for _, run := range runs {
+			mark := "! "
+			if run.start2 == run.end2 {
+				mark = "- "
+			} else if run.start1 == run.end1 {
+				mark = "+ "
+			}
+			for i := run.start1; i < run.end1; i++ {
+				marks1[i] = mark
+			}
+			for j := run.start2; j < run.end2; j++ {
+				marks2[j] = mark
+			}
+		}
+
+		result = append(result, "***************")
+		result = append(result, fmt.Sprintf("*** %s ****", contextRange(start1, end1)))
+		if len(marks1) > 0 {
+			result = append(result, contextSection(text1, start1, end1, marks1)...)
+		}
+		result = append(result, fmt.Sprintf("--- %s ----", contextRange(start2, end2)))
+		if len(marks2) > 0 {
+			result = append(result, contextSection(text2, start2, end2, marks2)...)
+		}
+	}
+	return result
+}
+
+func contextSection(text textFile, start, end int, marks map[int]string) []string {
+	var lines []string
+	for i := start; i < end; i++ {
+		mark, ok := marks[i]
+		if !ok {
+			mark = "  "
+		}
+		lines = append(lines, classicLine(mark, text, i)...)
+	}
+	return lines
+}
+
+// contextRange formats the lines [start, end) as diff -c does: "first,last",
+// just the line number for a single line, and for an empty range the line
+// before it.
+func contextRange(start, end int) string {
+	if end <= start+1 {
+		return fmt.Sprint(end)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end)
+}
+
+// formatNormalDiff renders hunk groups in the traditional format of diff
+// with no options, which has no context: each run of changes becomes an
+// "a" (add), "d" (delete) or "c" (change) command followed by the old
+// lines marked "<" and the new lines marked ">".
+func formatNormalDiff(text1, text2 textFile, groups [][]Edit) []string {
+	var result []string
+	for _, group := range groups {
+		for _, run := range changeRuns(group) {
+			switch {
+			case run.start1 == run.end1:
+				result = append(result, fmt.Sprintf("%da%s", run.start1, normalRange(run.start2, run.end2)))
+			case run.start2 == run.end2:
+				result = append(result, fmt.Sprintf("%sd%d", normalRange(run.start1, run.end1), run.start2))
+			default:
+				result = append(result, fmt.Sprintf("%sc%s", normalRange(run.start1, run.end1), normalRange(run.start2, run.end2)))
+			}
+			for i := run.start1; i < run.end1; i++ {
+				result = append(result, classicLine("< ", text1, i)...)
+			}
+			if run.start1 != run.end1 && run.start2 != run.end2 {
+				result = append(result, "---")
+			}
+			for j := run.start2; j < run.end2; j++ {
+				result = append(result, classicLine("> ", text2, j)...)
+			}
+		}
+	}
+	return result
+}
+
+// normalRange formats the non-empty lines [start, end) as "first,last", or
+// just the line number for a single line.
+func normalRange(start, end int) string {
+	if end == start+1 {
+		return fmt.Sprint(end)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end)
+}
+
+// classicFormat reports whether the output format is one of those of
+// traditional diff, which report files present on one side GNU-style.
+func classicFormat(config Config) bool {
+	switch config.format {
+	case "context", "normal", "ed", "rcs":
+		return true
+	}
+	return false
+}
+
+// printOnlyIn reports a file found in only one of the directories.
+func printOnlyIn(dir, relPath string) {
+	fmt.Printf("Only in %s: %s\n", filepath.Join(dir, filepath.Dir(relPath)), filepath.Base(relPath))
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContextRange(t *testing.T) {
+	cases := []struct {
+		start, end int
+		want       string
+	}{
+		{0, 0, "0"},
+		{3, 3, "3"},
+		{0, 1, "1"},
+		{4, 5, "5"},
+		{0, 10, "1,10"},
+	}
+	for _, c := range cases {
+		if got := contextRange(c.start, c.end); got != c.want {
+			t.Errorf("contextRange(%d, %d) = %q, want %q", c.start, c.end, got, c.want)
+		}
+	}
+}
+
+func TestClassicFormatsMatchGNUDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff not installed")
+	}
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	new := filepath.Join(dir, "new")
+	// A lone CR does not end a line for GNU diff, so it must not shift the
+	// line numbers
+	for _, files := range [][2]string{
+		{"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n", "a\nB\nc\nd\ne\nf\ng\nh\nj\nk"},
+		{"a\rb\nc\nd\n", "a\rb\nC\nd\n"},
+	} {
+		if err := os.WriteFile(old, []byte(files[0]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(new, []byte(files[1]), 0644); err != nil {
+			t.Fatal(err)
+		}
+		compareWithGNUDiff(t, old, new)
+	}
+}
+
+// compareWithGNUDiff checks the context and normal formats of the diff
+// between two files against GNU diff's.
+func compareWithGNUDiff(t *testing.T, old, new string) {
+	t.Helper()
+	for _, args := range [][]string{{"-c"}, {"-C", "1"}, {"-C", "0"}, {}} {
+		format, context := "normal", "3"
+		if len(args) > 0 {
+			format = "context"
+		}
+		if len(args) == 2 {
+			context = args[1]
+		}
+		want, _ := exec.Command("diff", append(args, old, new)...).Output()
+		got, err := exec.Command("./ddiff", "--color=false", "--format="+format, "-C="+context, old, new).Output()
+		if exitCode(err) != exitDifferent {
+			t.Fatalf("%v: CLI command failed: %v", args, err)
+		}
+
+		// GNU diff puts timestamps in the context format headers.
+		lines := strings.Split(string(want), "\n")
+		for i := 0; i < 2 && format == "context"; i++ {
+			lines[i], _, _ = strings.Cut(lines[i], "\t")
+		}
+		if strings.Join(lines, "\n") != string(got) {
+			t.Errorf("diff %v: expected\n%s\ngot\n%s", args, strings.Join(lines, "\n"), got)
+		}
+	}
+}
+
+func TestClassicFormatsRoundTripThroughPatch(t *testing.T) {
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch not installed")
+	}
+	binary, err := filepath.Abs("ddiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"context", "normal"} {
+		for _, c := range roundTripCorpus() {
+			if c.old == c.new {
+				continue
+			}
+			dir := t.TempDir()
+			writeTree(t, dir, map[string]string{"old/f": c.old, "new/f": c.new})
+
+			cmd := exec.Command(binary, "--color=false", "--format="+format, "-C=1", "old/f", "new/f")
+			cmd.Dir = dir
+			diff, err := cmd.Output()
+			if exitCode(err) != exitDifferent {
+				t.Fatalf("%s %s: ddiff failed: %v", format, c.name, err)
+			}
+
+			cmd = exec.Command("patch", "--batch", "--silent", "old/f")
+			if format == "normal" {
+				cmd.Args = append(cmd.Args, "--normal")
+			}
+			cmd.Dir = dir
+			cmd.Stdin = strings.NewReader(string(diff))
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%s %s: patch failed: %v\n%s\ndiff:\n%s", format, c.name, err, output, diff)
+			}
+			got, err := os.ReadFile(filepath.Join(dir, "old", "f"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.new {
+				t.Errorf("%s %s: patched file is %q, want %q\ndiff:\n%s", format, c.name, got, c.new, diff)
+			}
+		}
+	}
+}
+
+func TestCLINormalFormatInDirectories(t *testing.T) {
+	output, err := exec.Command("./ddiff", "--color=false", "--format=normal", "testdata/dir1", "testdata/dir2").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	want := fmt.Sprintf("Only in %s: only_in_dir1.txt\nOnly in %s: only_in_dir2.txt\ndiff %s %s\n2c2\n< line 2\n---\n> modified line 2\n",
+		filepath.Join("testdata", "dir1"), filepath.Join("testdata", "dir2"),
+		filepath.Join("testdata", "dir1", "shared.txt"), filepath.Join("testdata", "dir2", "shared.txt"))
+	if string(output) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, output)
+	}
+}
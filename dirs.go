@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// printOneSidedDir reports a directory found only in dir, which is the new
+// tree if added, in a single line standing for everything under it. The
+// name ends in a slash to tell it from a file.
+func printOneSidedDir(dir, relPath string, added bool, config Config) {
+	name := relPath + "/"
+	switch {
+	case config.listMode == "name-only":
+		fmt.Println(name)
+	case config.listMode == "name-status":
+		status := "D"
+		if added {
+			status = "A"
+		}
+		fmt.Printf("%s\t%s\n", status, name)
+	case structured(config) && showPatch(config):
+		if added {
+			printRecord(jsonFile{NewPath: name, Status: "added", NewType: "directory"}, config)
+		} else {
+			printRecord(jsonFile{OldPath: name, Status: "deleted", OldType: "directory"}, config)
+		}
+	case showPatch(config) || listing(config):
+		fmt.Printf("Only in %s: %s/\n", filepath.Join(dir, filepath.Dir(relPath)), filepath.Base(relPath))
+	}
+}
+
+// parentDirs returns the set of directories that hold any of the entries
+// in lists, at any depth.
+func parentDirs(lists ...[]string) map[string]bool {
+	parents := make(map[string]bool)
+	for _, list := range lists {
+		for _, relPath := range list {
+			for dir := filepath.Dir(relPath); dir != "." && !parents[dir]; dir = filepath.Dir(dir) {
+				parents[dir] = true
+			}
+		}
+	}
+	return parents
+}
+
+// insideAny reports whether relPath is under any of dirs.
+func insideAny(relPath string, dirs map[string]bool) bool {
+	for dir := filepath.Dir(relPath); dir != "."; dir = filepath.Dir(dir) {
+		if dirs[dir] {
+			return true
+		}
+	}
+	return false
+}
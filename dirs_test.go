@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParentDirs(t *testing.T) {
+	parents := parentDirs([]string{filepath.Join("a", "b", "c.txt"), "d.txt"}, []string{filepath.Join("e", "f")})
+	for _, dir := range []string{"a", filepath.Join("a", "b"), "e"} {
+		if !parents[dir] {
+			t.Errorf("expected %s to hold entries", dir)
+		}
+	}
+	if len(parents) != 3 {
+		t.Errorf("expected 3 parents, got %v", parents)
+	}
+
+	if !insideAny(filepath.Join("a", "b", "c.txt"), map[string]bool{"a": true}) {
+		t.Error("expected a/b/c.txt to be inside a")
+	}
+	if insideAny("a", map[string]bool{"a": true}) {
+		t.Error("a directory is not inside itself")
+	}
+}
+
+// dirTrees builds two trees where whole directories, one of them empty,
+// exist on only one side, and a directory on one side is a file on the
+// other.
+func dirTrees(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{
+		"common/x.txt":         "1\n",
+		"old/a.txt":            "a\n",
+		"old/deeper/b.txt":     "b\n",
+		"became-file/in.txt":   "in\n",
+		"common/gone/only.txt": "only\n",
+	})
+	writeTree(t, b, map[string]string{
+		"common/x.txt": "2\n",
+		"became-file":  "file\n",
+	})
+	if err := os.MkdirAll(filepath.Join(b, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return a, b
+}
+
+func TestListTreeRecordsDirectories(t *testing.T) {
+	_, b := dirTrees(t)
+	files, dirs, err := listTree(b, true, pathFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(files, ",") != "became-file,"+filepath.Join("common", "x.txt") {
+		t.Errorf("unexpected files %v", files)
+	}
+	if strings.Join(dirs, ",") != "common,empty" {
+		t.Errorf("unexpected directories %v", dirs)
+	}
+}
+
+func TestCLIOneSidedDirectories(t *testing.T) {
+	a, b := dirTrees(t)
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-q"}, "File " + filepath.Join(a, "became-file") + " is a directory while file " + filepath.Join(b, "became-file") + " is a regular file\n" +
+			"Only in " + filepath.Join(a, "common") + ": gone/\n" +
+			"Files " + filepath.Join(a, "common", "x.txt") + " and " + filepath.Join(b, "common", "x.txt") + " differ\n" +
+			"Only in " + b + ": empty/\n" +
+			"Only in " + a + ": old/\n"},
+		{[]string{"--name-status"}, "T\tbecame-file\nD\t" + filepath.Join("common", "gone") + "/\nM\t" + filepath.Join("common", "x.txt") + "\nA\tempty/\nD\told/\n"},
+	}
+	for _, c := range cases {
+		args := append(append([]string{}, c.args...), a, b)
+		output, err := exec.Command("./ddiff", args...).Output()
+		if exitCode(err) != exitDifferent {
+			t.Fatalf("%v: CLI command failed: %v", c.args, err)
+		}
+		if string(output) != c.want {
+			t.Errorf("%v: expected\n%s\ngot\n%s", c.args, c.want, output)
+		}
+	}
+
+	// Files under a collapsed directory still count in the statistics
+	output, err := exec.Command("./ddiff", "--shortstat", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if want := " 6 files changed, 1 insertion(+), 5 deletions(-)\n"; string(output) != want {
+		t.Errorf("expected %q, got %q", want, output)
+	}
+}
+
+func TestCLIOneSidedDirectoriesJSON(t *testing.T) {
+	a, b := dirTrees(t)
+	output, err := exec.Command("./ddiff", "--format=json", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	records := decodeJSONLines(t, output)
+	if len(records) != 5 {
+		t.Fatalf("expected 5 records, got %d:\n%s", len(records), output)
+	}
+	if r := records[3]; r.Status != "added" || r.NewPath != "empty/" || r.NewType != "directory" {
+		t.Errorf("unexpected record for the empty directory %+v", r)
+	}
+	if r := records[4]; r.Status != "deleted" || r.OldPath != "old/" || r.OldType != "directory" {
+		t.Errorf("unexpected record for the deleted directory %+v", r)
+	}
+}
+
+func TestCLINewFileExpandsDirectories(t *testing.T) {
+	a, b := dirTrees(t)
+	output, err := exec.Command("./ddiff", "-c=false", "-N", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	// Every file is needed for the patch, and only the empty directory,
+	// which a patch cannot create, is named
+	for _, s := range []string{
+		"--- " + filepath.Join("old", "deeper", "b.txt") + "\n+++ /dev/null\n",
+		"--- " + filepath.Join("became-file", "in.txt") + "\n+++ /dev/null\n",
+		"Only in " + b + ": empty/\n",
+	} {
+		if !strings.Contains(string(output), s) {
+			t.Errorf("expected the output to contain\n%s\ngot\n%s", s, output)
+		}
+	}
+	if strings.Contains(string(output), "old/\n") {
+		t.Errorf("expected no line for a directory with files, got\n%s", output)
+	}
+}
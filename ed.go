@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allChangeRuns returns the runs of changes in every group, in order.
+func allChangeRuns(groups [][]Edit) []changeRun {
+	var runs []changeRun
+	for _, group := range groups {
+		runs = append(runs, changeRuns(group)...)
+	}
+	return runs
+}
+
+// rawLine returns line i of text as it appears in the file, less its
+// newline.
+func rawLine(text textFile, i int) string {
+	return text.lines[i] + strings.TrimSuffix(text.eol(i), "\n")
+}
+
+// formatEdScript renders the changes as a script of ed commands that turns
+// the first file into the second, as diff -e does. The commands run from
+// the end of the file to the start so that each one's line numbers are
+// still those of the original file. ed has no way to express a missing
+// final newline, so that is not preserved.
+func formatEdScript(text1, text2 textFile, groups [][]Edit) string {
+	var b strings.Builder
+	runs := allChangeRuns(groups)
+	for k := len(runs) - 1; k >= 0; k-- {
+		run := runs[k]
+		switch {
+		case run.start1 == run.end1:
+			fmt.Fprintf(&b, "%da\n", run.start1)
+		case run.start2 == run.end2:
+			fmt.Fprintf(&b, "%sd\n", normalRange(run.start1, run.end1))
+			continue
+		default:
+			fmt.Fprintf(&b, "%sc\n", normalRange(run.start1, run.end1))
+		}
+
+		inserting := true
+		for j := run.start2; j < run.end2; j++ {
+			if !inserting {
+				b.WriteString("a\n")
+				inserting = true
+			}
+			line := rawLine(text2, j)
+			if line == "." {
+				// A lone dot would end the insertion, so write two and
+				// remove one afterwards, then carry on appending.
+				b.WriteString("..\n.\ns/.//\n")
+				inserting = false
+				continue
+			}
+			b.WriteString(line + "\n")
+		}
+		if inserting {
+			b.WriteString(".\n")
+		}
+	}
+	return b.String()
+}
+
+// formatRCSDiff renders the changes in the RCS format of diff -n: "dN M"
+// deletes M lines starting at line N and "aN M" adds the M lines that
+// follow after line N, with line numbers always those of the original
+// file. An unterminated last line is written as such.
+func formatRCSDiff(text1, text2 textFile, groups [][]Edit) string {
+	var b strings.Builder
+	for _, run := range allChangeRuns(groups) {
+		if run.end1 > run.start1 {
+			fmt.Fprintf(&b, "d%d %d\n", run.start1+1, run.end1-run.start1)
+		}
+		if run.end2 > run.start2 {
+			fmt.Fprintf(&b, "a%d %d\n", run.end1, run.end2-run.start2)
+			for j := run.start2; j < run.end2; j++ {
+				b.WriteString(text2.lines[j] + text2.eol(j))
+			}
+		}
+	}
+	return b.String()
+}
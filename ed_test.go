@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runEdScript is an interpreter for the subset of ed that diff -e uses:
+// "Na", "N[,M]c" and "N[,M]d" with their text ended by ".", and "s/.//",
+// which removes the first character of the current line. lines are those
+// of the file without their newlines.
+func runEdScript(lines []string, script string) ([]string, error) {
+	commands := strings.Split(script, "\n")
+	if commands[len(commands)-1] == "" {
+		commands = commands[:len(commands)-1]
+	}
+	buffer := append([]string(nil), lines...)
+	current := len(buffer) // 1-based current line, as ed leaves it
+
+	for i := 0; i < len(commands); i++ {
+		command := commands[i]
+		if command == "s/.//" {
+			if current < 1 || current > len(buffer) || buffer[current-1] == "" {
+				return nil, fmt.Errorf("command %d: no match for s/.//", i+1)
+			}
+			buffer[current-1] = buffer[current-1][1:]
+			continue
+		}
+		if command == "a" {
+			command = strconv.Itoa(current) + "a"
+		}
+
+		op := command[len(command)-1]
+		first, last, err := parseEdRange(command[:len(command)-1])
+		if err != nil || first < 0 || last > len(buffer) {
+			return nil, fmt.Errorf("command %d: bad address in %q", i+1, command)
+		}
+
+		var text []string
+		if op == 'a' || op == 'c' {
+			for i++; i < len(commands) && commands[i] != "."; i++ {
+				text = append(text, commands[i])
+			}
+			if i == len(commands) {
+				return nil, fmt.Errorf("unterminated text for %q", command)
+			}
+		}
+
+		switch op {
+		case 'a':
+			buffer = append(buffer[:last], append(text, buffer[last:]...)...)
+			current = last + len(text)
+		case 'c', 'd':
+			if first < 1 {
+				return nil, fmt.Errorf("command %d: bad address in %q", i+1, command)
+			}
+			buffer = append(buffer[:first-1], append(text, buffer[last:]...)...)
+			current = first - 1 + len(text)
+			if op == 'd' && current < len(buffer) {
+				current++
+			}
+		default:
+			return nil, fmt.Errorf("command %d: unsupported command %q", i+1, command)
+		}
+	}
+	return buffer, nil
+}
+
+func parseEdRange(s string) (int, int, error) {
+	a, b, found := strings.Cut(s, ",")
+	first, err := strconv.Atoi(a)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return first, first, nil
+	}
+	last, err := strconv.Atoi(b)
+	return first, last, err
+}
+
+// runRCSDiff applies an RCS format diff, whose line numbers all refer to
+// the original file.
+func runRCSDiff(text string, diff string) (string, error) {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	commands := strings.SplitAfter(diff, "\n")
+
+	var out []string
+	next := 0 // index of the next original line to copy
+	for i := 0; i < len(commands) && commands[i] != ""; i++ {
+		var op byte
+		var at, count int
+		if _, err := fmt.Sscanf(commands[i], "%c%d %d\n", &op, &at, &count); err != nil {
+			return "", fmt.Errorf("bad command %q", commands[i])
+		}
+		switch op {
+		case 'd':
+			out = append(out, lines[next:at-1]...)
+			next = at - 1 + count
+		case 'a':
+			out = append(out, lines[next:at]...)
+			next = at
+			out = append(out, commands[i+1:i+1+count]...)
+			i += count
+		default:
+			return "", fmt.Errorf("bad command %q", commands[i])
+		}
+	}
+	out = append(out, lines[next:]...)
+	return strings.Join(out, ""), nil
+}
+
+func TestEdScriptReproducesSecondFile(t *testing.T) {
+	corpus := append(roundTripCorpus(),
+		roundTripCase{"lone dots", "a\nb\n", ".\na\n.\n.\nb\n.\n"},
+		roundTripCase{"dot in change", "a\nb\nc\n", "a\n.\nx\nc\n"},
+	)
+	for _, c := range corpus {
+		text1, text2 := splitLines(c.old), splitLines(c.new)
+		config := Config{}
+		script := formatEdScript(text1, text2, diffGroups(text1, text2, config))
+
+		var old, want []string
+		for i := range text1.lines {
+			old = append(old, rawLine(text1, i))
+		}
+		for i := range text2.lines {
+			want = append(want, rawLine(text2, i))
+		}
+		got, err := runEdScript(old, script)
+		if err != nil {
+			t.Fatalf("%s: %v\nscript:\n%s", c.name, err, script)
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") || len(got) != len(want) {
+			t.Errorf("%s: ed produced %q, want %q\nscript:\n%s", c.name, got, want, script)
+		}
+	}
+}
+
+func TestRCSDiffReproducesSecondFile(t *testing.T) {
+	for _, c := range roundTripCorpus() {
+		text1, text2 := splitLines(c.old), splitLines(c.new)
+		diff := formatRCSDiff(text1, text2, diffGroups(text1, text2, Config{}))
+		got, err := runRCSDiff(c.old, diff)
+		if err != nil {
+			t.Fatalf("%s: %v\ndiff:\n%s", c.name, err, diff)
+		}
+		if got != c.new {
+			t.Errorf("%s: applying the RCS diff gave %q, want %q\ndiff:\n%s", c.name, got, c.new, diff)
+		}
+	}
+}
+
+func TestEdAndRCSMatchGNUDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff not installed")
+	}
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	new := filepath.Join(dir, "new")
+	if err := os.WriteFile(old, []byte("a\nb\nc\nd\ne\nf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(new, []byte(".\na\nB\nc\ne\nf\ng\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, flag := range []string{"-e", "-n"} {
+		want, _ := exec.Command("diff", flag, old, new).Output()
+		got, err := exec.Command("./ddiff", flag, old, new).Output()
+		if exitCode(err) != exitDifferent {
+			t.Fatalf("%s: CLI command failed: %v", flag, err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", flag, want, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// entryType names the kind of a directory entry as GNU diff does in its
+// messages.
+func entryType(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "regular file"
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symbolic link"
+	case mode&fs.ModeNamedPipe != 0:
+		return "fifo"
+	case mode&fs.ModeSocket != 0:
+		return "socket"
+	case mode&fs.ModeCharDevice != 0:
+		return "character special file"
+	case mode&fs.ModeDevice != 0:
+		return "block special file"
+	}
+	return "special file"
+}
+
+// permissions returns the permission bits of a mode, including the set-id
+// and sticky bits, which are all compared between trees.
+func permissions(mode fs.FileMode) fs.FileMode {
+	return mode & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+}
+
+// formatMode formats permissions in octal, as chmod takes them.
+func formatMode(mode fs.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
+
+// setModes records a change of permissions in a structured record, making
+// it a mode change if nothing else about the file changed.
+func setModes(record *jsonFile, pair filePair) {
+	if pair.mode1 == pair.mode2 {
+		return
+	}
+	record.OldMode, record.NewMode = formatMode(pair.mode1), formatMode(pair.mode2)
+	if record.Status == "modified" && !pair.differ {
+		record.Status = "mode-changed"
+	}
+}
+
+// compareSpecial compares two entries at the same path that are not both
+// regular files, without reading through them: entries of different types
+// are reported as a type change, symbolic links by their targets, and
+// other entries of the same type, such as two fifos, are taken as equal.
+func compareSpecial(dir1, dir2, relPath string, info1, info2 fs.FileInfo, config Config) (fileStat, error) {
+	stat := fileStat{path: relPath}
+	path1, path2 := filepath.Join(dir1, relPath), filepath.Join(dir2, relPath)
+	type1, type2 := entryType(info1.Mode()), entryType(info2.Mode())
+
+	if type1 != type2 {
+		stat.differs = true
+		switch {
+		case gitHeaderFormat(config):
+			// git has no type change, only a deletion and a creation. A
+			// directory's files are each created or deleted in turn.
+			if !info1.IsDir() {
+				if err := diffOneSided(dir1, relPath, false, config); err != nil {
+					return stat, err
+				}
+			}
+			if info2.IsDir() {
+				return stat, nil
+			}
+			return stat, diffOneSided(dir2, relPath, true, config)
+		case config.listMode == "name-only":
+			fmt.Println(relPath)
+		case config.listMode == "name-status":
+			fmt.Printf("T\t%s\n", relPath)
+		case structured(config) && showPatch(config):
+			printRecord(jsonFile{OldPath: relPath, NewPath: relPath, Status: "type-changed", OldType: type1, NewType: type2}, config)
+		case showPatch(config) || listing(config):
+			fmt.Printf("File %s is a %s while file %s is a %s\n", path1, type1, path2, type2)
+		}
+		return stat, nil
+	}
+
+	if type1 != "symbolic link" {
+		return stat, nil
+	}
+	target1, err := os.Readlink(path1)
+	if err != nil {
+		return stat, err
+	}
+	target2, err := os.Readlink(path2)
+	if err != nil {
+		return stat, err
+	}
+	if target1 == target2 {
+		return stat, nil
+	}
+
+	if gitHeaderFormat(config) {
+		// git stores a link as a blob holding its target
+		return printFilePair(linkPair(target1, target2, config), path1, path2, relPath, relPath, relPath, nil, config), nil
+	}
+	stat.differs = true
+	switch {
+	case config.listMode == "name-only":
+		fmt.Println(relPath)
+	case config.listMode == "name-status":
+		fmt.Printf("M\t%s\n", relPath)
+	case structured(config) && showPatch(config):
+		printRecord(jsonFile{OldPath: relPath, NewPath: relPath, Status: "symlink-changed", OldTarget: target1, NewTarget: target2}, config)
+	case showPatch(config) || listing(config):
+		fmt.Printf("Symbolic links %s -> %s and %s -> %s differ\n", path1, target1, path2, target2)
+	}
+	return stat, nil
+}
+
+// linkPair compares the targets of two symbolic links as the content of
+// files with no final newline, as git does. An empty target stands for a
+// missing link.
+func linkPair(target1, target2 string, config Config) filePair {
+	pair := filePair{text1: splitLines(target1), text2: splitLines(target2), differ: target1 != target2}
+	pair.groups = diffGroups(pair.text1, pair.text2, config)
+	return pair
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntryType(t *testing.T) {
+	cases := []struct {
+		mode fs.FileMode
+		want string
+	}{
+		{0644, "regular file"},
+		{fs.ModeDir | 0755, "directory"},
+		{fs.ModeSymlink | 0777, "symbolic link"},
+		{fs.ModeNamedPipe | 0644, "fifo"},
+		{fs.ModeSocket, "socket"},
+		{fs.ModeDevice | fs.ModeCharDevice, "character special file"},
+		{fs.ModeDevice, "block special file"},
+	}
+	for _, c := range cases {
+		if got := entryType(c.mode); got != c.want {
+			t.Errorf("entryType(%v) = %q, want %q", c.mode, got, c.want)
+		}
+	}
+}
+
+func TestFormatMode(t *testing.T) {
+	if got := formatMode(0755); got != "0755" {
+		t.Errorf("expected 0755, got %s", got)
+	}
+	if got := formatMode(fs.ModeSetuid | fs.ModeSticky | 0700); got != "5700" {
+		t.Errorf("expected 5700, got %s", got)
+	}
+}
+
+// entryTrees builds two trees differing in the permissions, link targets
+// and types of their entries, but not in any file's content.
+func entryTrees(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"run.sh": "echo hi\n", "target.txt": "x\n", "same": "s\n", "became-link": "y\n"})
+	writeTree(t, b, map[string]string{"run.sh": "echo hi\n", "target.txt": "x\n", "same": "s\n"})
+	if err := os.Chmod(filepath.Join(b, "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, link := range []struct{ tree, name, target string }{
+		{a, "link", "target.txt"},
+		{b, "link", "elsewhere.txt"},
+		{a, "same-link", "same"},
+		{b, "same-link", "same"},
+		{b, "became-link", "target.txt"},
+	} {
+		if err := os.Symlink(link.target, filepath.Join(link.tree, link.name)); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+	if err := exec.Command("mkfifo", filepath.Join(a, "pipe"), filepath.Join(b, "pipe")).Run(); err != nil {
+		t.Skipf("fifos not supported: %v", err)
+	}
+	return a, b
+}
+
+func TestCLIEntryChanges(t *testing.T) {
+	a, b := entryTrees(t)
+
+	output, err := exec.Command("./ddiff", "-c=false", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v\n%s", err, output)
+	}
+	want := "File " + filepath.Join(a, "became-link") + " is a regular file while file " + filepath.Join(b, "became-link") + " is a symbolic link\n" +
+		"Symbolic links " + filepath.Join(a, "link") + " -> target.txt and " + filepath.Join(b, "link") + " -> elsewhere.txt differ\n" +
+		"File " + filepath.Join(a, "run.sh") + " has mode 0644 while file " + filepath.Join(b, "run.sh") + " has mode 0755\n"
+	if string(output) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, output)
+	}
+
+	output, err = exec.Command("./ddiff", "--name-status", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if want := "T\tbecame-link\nM\tlink\nM\trun.sh\n"; string(output) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, output)
+	}
+}
+
+func TestCLIModeChangeNamesFile(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"tool": "v1\n"})
+	writeTree(t, b, map[string]string{"tool": "v2\n"})
+	if err := os.Chmod(filepath.Join(b, "tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("./ddiff", "-c=false", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	want := "File " + filepath.Join(a, "tool") + " has mode 0644 while file " + filepath.Join(b, "tool") + " has mode 0755\n--- tool\n+++ tool\n"
+	if !strings.HasPrefix(string(output), want) {
+		t.Errorf("expected output to start with\n%s\ngot\n%s", want, output)
+	}
+}
+
+func TestCLIEntryChangesJSON(t *testing.T) {
+	a, b := entryTrees(t)
+
+	output, err := exec.Command("./ddiff", "--format=json", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	records := decodeJSONLines(t, output)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d:\n%s", len(records), output)
+	}
+	if r := records[0]; r.Status != "type-changed" || r.OldType != "regular file" || r.NewType != "symbolic link" {
+		t.Errorf("unexpected type change record %+v", r)
+	}
+	if r := records[1]; r.Status != "symlink-changed" || r.OldTarget != "target.txt" || r.NewTarget != "elsewhere.txt" {
+		t.Errorf("unexpected link record %+v", r)
+	}
+	if r := records[2]; r.Status != "mode-changed" || r.OldMode != "0644" || r.NewMode != "0755" || r.NewPath != "run.sh" {
+		t.Errorf("unexpected mode record %+v", r)
+	}
+}
+
+func TestCLIGitSymlinkPatch(t *testing.T) {
+	a, b := entryTrees(t)
+
+	output, err := exec.Command("./ddiff", "-c=false", "--git", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	want := "diff --git a/link b/link\nindex 4cbb553..77d3738 120000\n--- a/link\n+++ b/link\n@@ -1 +1 @@\n-target.txt\n\\ No newline at end of file\n+elsewhere.txt\n\\ No newline at end of file\n"
+	if !strings.Contains(string(output), want) {
+		t.Errorf("expected the patch to contain\n%s\ngot\n%s", want, output)
+	}
+	// git has no type change, only a deletion and a creation
+	want = "diff --git a/became-link b/became-link\ndeleted file mode 100644\n"
+	if !strings.Contains(string(output), want) || !strings.Contains(string(output), "new file mode 120000\n") {
+		t.Errorf("expected the type change as a deletion and a creation, got\n%s", output)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// pathFilter selects the files a directory comparison looks at. A path
+// matching any exclude pattern is skipped, and if there are include
+// patterns a file must match one of them. Include patterns never prune
+// directories, so "*.go" finds Go files at any depth.
+//
+// A pattern without a slash matches the name of a file or directory
+// anywhere in the tree. One with a slash matches the whole path relative
+// to the directory being compared, where "**" stands for any number of
+// directories. A trailing slash restricts a pattern to directories.
+//
+// ignore, if set, also skips whatever git would ignore in the tree being
+// listed.
+type pathFilter struct {
+	exclude []string
+	include []string
+	ignore  *gitignore
+}
+
+// skip reports whether relPath should be left out of the comparison. When
+// it is a directory, its whole subtree is left out.
+func (f pathFilter) skip(relPath string, isDir bool) bool {
+	relPath = filepath.ToSlash(relPath)
+	for _, pattern := range f.exclude {
+		if matchPattern(pattern, relPath, isDir) {
+			return true
+		}
+	}
+	if isDir || len(f.include) == 0 {
+		return false
+	}
+	for _, pattern := range f.include {
+		if matchPattern(pattern, relPath, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPattern reports whether the slash-separated relPath matches pattern.
+func matchPattern(pattern, relPath string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	if !strings.Contains(pattern, "/") {
+		return matchSegments([]string{pattern}, []string{path.Base(relPath)})
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+// matchSegments matches path components against pattern components, where
+// a "**" component matches zero or more path components.
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegments(pattern, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// validPattern checks the syntax of each component of a pattern, since
+// path.Match only reports a malformed pattern when it gets that far.
+func validPattern(pattern string) error {
+	for _, component := range strings.Split(pattern, "/") {
+		if _, err := path.Match(component, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// patternFlag is a glob pattern flag that may be repeated, each use adding
+// to the list.
+type patternFlag struct {
+	patterns *[]string
+}
+
+func (f patternFlag) String() string {
+	if f.patterns == nil {
+		return ""
+	}
+	return strings.Join(*f.patterns, " ")
+}
+
+func (f patternFlag) Set(value string) error {
+	if err := validPattern(value); err != nil {
+		return err
+	}
+	*f.patterns = append(*f.patterns, value)
+	return nil
+}
+
+// readPatterns reads patterns one per line from a file, as for
+// --exclude-from, skipping blank lines and lines starting with "#".
+func readPatterns(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := validPattern(line); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.o", "main.o", false, true},
+		{"*.o", "build/obj/main.o", false, true},
+		{"*.o", "main.go", false, false},
+		{"node_modules", "web/node_modules", true, true},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"src/*.go", "src/main.go", false, true},
+		{"src/*.go", "src/sub/main.go", false, false},
+		{"src/*.go", "lib/src/main.go", false, false},
+		{"/src/*.go", "src/main.go", false, true},
+		{"src/**/*.go", "src/main.go", false, true},
+		{"src/**/*.go", "src/a/b/main.go", false, true},
+		{"**/testdata", "testdata", true, true},
+		{"**/testdata", "a/b/testdata", true, true},
+		{"docs/**", "docs/a/b.md", false, true},
+		{"docs/**", "src/a.md", false, false},
+	}
+	for _, c := range cases {
+		if got := matchPattern(c.pattern, c.path, c.isDir); got != c.want {
+			t.Errorf("matchPattern(%q, %q, %v) = %v, want %v", c.pattern, c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestPathFilterSkip(t *testing.T) {
+	filter := pathFilter{exclude: []string{"vendor"}, include: []string{"*.go"}}
+	cases := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"main.go", false, false},
+		{"README.md", false, true},
+		{"src", true, false}, // includes never prune directories
+		{"vendor", true, true},
+	}
+	for _, c := range cases {
+		if got := filter.skip(c.path, c.isDir); got != c.want {
+			t.Errorf("skip(%q, %v) = %v, want %v", c.path, c.isDir, got, c.want)
+		}
+	}
+}
+
+func TestPatternFlagRejectsBadPatterns(t *testing.T) {
+	var patterns []string
+	if err := (patternFlag{&patterns}).Set("a/[b"); err == nil {
+		t.Error("expected an error for an unterminated character class")
+	}
+	if err := (patternFlag{&patterns}).Set("*.o"); err != nil || len(patterns) != 1 {
+		t.Errorf("expected the pattern to be added, got %v, %q", err, patterns)
+	}
+}
+
+func TestGetFileListPrunesExcludedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"main.go":                   "",
+		"main.o":                    "",
+		"node_modules/pkg/index.js": "",
+		"src/util.go":               "",
+	})
+	files, err := getFileList(dir, true, pathFilter{exclude: []string{"node_modules", "*.o"}})
+	if err != nil {
+		t.Fatalf("getFileList: %v", err)
+	}
+	got := strings.Join(files, " ")
+	want := strings.Join([]string{"main.go", filepath.Join("src", "util.go")}, " ")
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCLIExcludeInclude(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{
+		"main.go":       "package main\n",
+		"main.o":        "old object\n",
+		"docs/guide.md": "old guide\n",
+	})
+	writeTree(t, filepath.Join(dir, "b"), map[string]string{
+		"main.go":       "package main\n",
+		"main.o":        "new object\n",
+		"docs/guide.md": "new guide\n",
+	})
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	output, err := exec.Command("./ddiff", "-c=false", "-x", "*.o", "--exclude", "docs/", a, b).CombinedOutput()
+	if exitCode(err) != exitSame {
+		t.Errorf("expected no differences outside excluded files, got %v:\n%s", err, output)
+	}
+
+	output, err = exec.Command("./ddiff", "-c=false", "--include", "**/*.md", a, b).CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("expected differences, got %v:\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "+new guide") || strings.Contains(string(output), "object") {
+		t.Errorf("expected only the included file to be compared, got\n%s", output)
+	}
+
+	patterns := filepath.Join(dir, "patterns")
+	if err := os.WriteFile(patterns, []byte("# build output\n*.o\n\ndocs\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err = exec.Command("./ddiff", "--exclude-from", patterns, a, b).CombinedOutput()
+	if exitCode(err) != exitSame {
+		t.Errorf("expected --exclude-from to skip every difference, got %v:\n%s", err, output)
+	}
+
+	_, err = exec.Command("./ddiff", "--exclude-from", filepath.Join(dir, "missing"), a, b).CombinedOutput()
+	if exitCode(err) != exitTrouble {
+		t.Errorf("expected a missing pattern file to be trouble, got %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// Default path prefixes of --git headers, as in git.
+const (
+	defaultSrcPrefix = "a/"
+	defaultDstPrefix = "b/"
+)
+
+// prefixFlag is --src-prefix or --dst-prefix, remembering whether it was
+// given so that --git can supply the default only when it was not.
+type prefixFlag struct {
+	prefix *string
+	set    *bool
+}
+
+func (f prefixFlag) String() string {
+	if f.prefix == nil {
+		return ""
+	}
+	return *f.prefix
+}
+
+func (f prefixFlag) Set(value string) error {
+	*f.prefix = value
+	*f.set = true
+	return nil
+}
+
+// withPrefix adds a header path prefix to a label, except to the name of
+// the missing side of a created or deleted file.
+func withPrefix(prefix, label string) string {
+	if label == devNull {
+		return label
+	}
+	return prefix + label
+}
+
+// gitMode returns the mode git records for a file: 100755 for an
+// executable file, 120000 for a symbolic link and 100644 for any other.
+// The missing side of a created or deleted file has no mode.
+func gitMode(path string) string {
+	if path == os.DevNull {
+		return ""
+	}
+	if path == stdinName {
+		return "100644"
+	}
+	info, err := os.Lstat(path)
+	switch {
+	case err != nil:
+		return ""
+	case info.Mode()&os.ModeSymlink != 0:
+		return "120000"
+	case info.Mode().Perm()&0111 != 0:
+		return "100755"
+	}
+	return "100644"
+}
+
+// gitBlobHash returns the abbreviated hash git gives the content of a file
+// as a blob, or zeros for the missing side of a created or deleted file.
+func gitBlobHash(path string) string {
+	if path == os.DevNull {
+		return "0000000"
+	}
+	var data []byte
+	var err error
+	if path == stdinName {
+		text, stdinErr := readStdin()
+		if stdinErr != nil {
+			return "0000000"
+		}
+		data = []byte(text.String())
+	} else if target, linkErr := os.Readlink(path); linkErr == nil {
+		data = []byte(target)
+	} else if data, err = os.ReadFile(path); err != nil {
+		return "0000000"
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))[:7]
+}
+
+// gitHeaders returns the extended header lines git writes before the
+// "---" and "+++" lines of a file: "diff --git" naming both paths, then
+// lines for a created or deleted file, a change of mode, a rename or copy,
+// and the blob hashes of the two versions when their content differs.
+func gitHeaders(file1, file2, label1, label2 string, r *rename, contentDiffers bool, config Config) []string {
+	name1, name2 := label1, label2
+	if name1 == devNull {
+		name1 = name2
+	} else if name2 == devNull {
+		name2 = name1
+	}
+	headers := []string{fmt.Sprintf("diff --git %s%s %s%s", config.srcPrefix, name1, config.dstPrefix, name2)}
+
+	mode1, mode2 := gitMode(file1), gitMode(file2)
+	switch {
+	case mode1 == "":
+		headers = append(headers, "new file mode "+mode2)
+	case mode2 == "":
+		headers = append(headers, "deleted file mode "+mode1)
+	case mode1 != mode2:
+		headers = append(headers, "old mode "+mode1, "new mode "+mode2)
+	}
+
+	if r != nil {
+		kind := "rename"
+		if r.copy {
+			kind = "copy"
+		}
+		headers = append(headers,
+			fmt.Sprintf("similarity index %d%%", r.similarity),
+			fmt.Sprintf("%s from %s", kind, r.from),
+			fmt.Sprintf("%s to %s", kind, r.to))
+	}
+
+	if contentDiffers {
+		index := "index " + gitBlobHash(file1) + ".." + gitBlobHash(file2)
+		if mode1 == mode2 {
+			index += " " + mode1
+		}
+		headers = append(headers, index)
+	}
+	return headers
+}
+
+// gitHeaderFormat reports whether the output format takes --git headers,
+// which only unified diffs do.
+func gitHeaderFormat(config Config) bool {
+	return config.git && config.format == "unified" && showPatch(config)
+}
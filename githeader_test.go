@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitBlobHash(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f")
+	if err := os.WriteFile(file, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// git hash-object of "hello\n"
+	if got := gitBlobHash(file); got != "ce01362" {
+		t.Errorf("expected ce01362, got %s", got)
+	}
+	if got := gitBlobHash(os.DevNull); got != "0000000" {
+		t.Errorf("expected zeros for a missing file, got %s", got)
+	}
+}
+
+func TestGitHeaders(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"plain": "x\n", "exec": "x\n"})
+	plain, executable := filepath.Join(dir, "plain"), filepath.Join(dir, "exec")
+	if err := os.Chmod(executable, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := Config{srcPrefix: "a/", dstPrefix: "b/"}
+
+	cases := []struct {
+		name         string
+		file1, file2 string
+		label1       string
+		label2       string
+		r            *rename
+		differ       bool
+		want         []string
+	}{
+		{"mode", plain, executable, "f", "f", nil, false,
+			[]string{"diff --git a/f b/f", "old mode 100644", "new mode 100755"}},
+		{"new", os.DevNull, plain, devNull, "f", nil, true,
+			[]string{"diff --git a/f b/f", "new file mode 100644", "index 0000000..587be6b"}},
+		{"deleted", executable, os.DevNull, "f", devNull, nil, true,
+			[]string{"diff --git a/f b/f", "deleted file mode 100755", "index 587be6b..0000000"}},
+		{"copy", plain, plain, "f", "g", &rename{from: "f", to: "g", similarity: 100, copy: true}, false,
+			[]string{"diff --git a/f b/g", "similarity index 100%", "copy from f", "copy to g"}},
+	}
+	for _, c := range cases {
+		got := gitHeaders(c.file1, c.file2, c.label1, c.label2, c.r, c.differ, config)
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s: expected\n%s\ngot\n%s", c.name, strings.Join(c.want, "\n"), strings.Join(got, "\n"))
+		}
+	}
+}
+
+func TestCLIGitPatchAppliesWithGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	writeTree(t, old, map[string]string{
+		"x.txt":     "1\n2\n3\n",
+		"run.sh":    "echo hi\n",
+		"gone.txt":  "bye\n",
+		"moved.txt": "a\nb\nc\nd\ne\n",
+	})
+	new := filepath.Join(dir, "new")
+	writeTree(t, new, map[string]string{
+		"x.txt":         "1\ntwo\n3\n",
+		"run.sh":        "echo hi\n",
+		"sub/added.txt": "hello\n",
+		"renamed.txt":   "a\nb\nc\nd\nE\n",
+	})
+	if err := os.Chmod(filepath.Join(new, "run.sh"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	patch, err := exec.Command("./ddiff", "-c=false", "--git", "-M", old, new).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	for _, s := range []string{
+		"diff --git a/moved.txt b/renamed.txt\nsimilarity index 80%\nrename from moved.txt\nrename to renamed.txt\n",
+		"diff --git a/run.sh b/run.sh\nold mode 100644\nnew mode 100755\n",
+		"diff --git a/x.txt b/x.txt\nindex 01e79c3..d8eb098 100644\n--- a/x.txt\n+++ b/x.txt\n",
+	} {
+		if !strings.Contains(string(patch), s) {
+			t.Errorf("expected the patch to contain\n%s\ngot\n%s", s, patch)
+		}
+	}
+
+	patchFile := filepath.Join(dir, "patch.diff")
+	if err := os.WriteFile(patchFile, patch, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", "apply", "-p1", patchFile)
+	cmd.Dir = old
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git apply failed: %v\n%s\npatch:\n%s", err, output, patch)
+	}
+	if output, err := exec.Command("./ddiff", "-c=false", "--git", old, new).CombinedOutput(); err != nil {
+		t.Errorf("patched tree differs from the new tree: %v\n%s", err, output)
+	}
+}
+
+func TestCLIPrefixes(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--src-prefix=old/", "--dst-prefix=new/"}, "--- old/testdata/file1.txt\n+++ new/testdata/file2.txt\n"},
+		{[]string{"--git", "--no-prefix"}, "diff --git testdata/file1.txt testdata/file2.txt\n"},
+		{[]string{"--git"}, "diff --git a/testdata/file1.txt b/testdata/file2.txt\n"},
+	}
+	for _, c := range cases {
+		args := append(append([]string{"-c=false"}, c.args...), "testdata/file1.txt", "testdata/file2.txt")
+		output, err := exec.Command("./ddiff", args...).Output()
+		if exitCode(err) != exitDifferent {
+			t.Fatalf("%v: CLI command failed: %v", c.args, err)
+		}
+		if !strings.HasPrefix(string(output), c.want) {
+			t.Errorf("%v: expected output to start with\n%s\ngot\n%s", c.args, c.want, output)
+		}
+	}
+}
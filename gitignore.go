@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignorePattern is one line of a .gitignore file.
+type ignorePattern struct {
+	segments []string // pattern split at slashes
+	negate   bool     // "!pattern" re-includes what earlier patterns ignored
+	dirOnly  bool     // "pattern/" matches only directories
+	anchored bool     // a slash other than at the end ties it to its directory
+}
+
+// parseIgnoreLine parses a line of a .gitignore file, reporting false for
+// blank lines and comments.
+func parseIgnoreLine(line string) (ignorePattern, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped with a backslash.
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	var p ignorePattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+	p.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	// gitignore negates a character class with "!", path.Match with "^".
+	line = strings.ReplaceAll(line, "[!", "[^")
+	p.segments = strings.Split(line, "/")
+	for _, segment := range p.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return ignorePattern{}, false
+		}
+	}
+	return p, true
+}
+
+// match reports whether the pattern matches a path, given as components
+// relative to the directory of the file the pattern came from.
+func (p ignorePattern) match(components []string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], components[len(components)-1])
+		return ok
+	}
+	// A trailing "/**" matches everything inside a directory, but not the
+	// directory itself.
+	if p.segments[len(p.segments)-1] == "**" && len(components) < len(p.segments) {
+		return false
+	}
+	return matchSegments(p.segments, components)
+}
+
+// readIgnoreFile reads the patterns in a .gitignore style file, which need
+// not exist.
+func readIgnoreFile(name string) ([]ignorePattern, error) {
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []ignorePattern
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if p, ok := parseIgnoreLine(scanner.Text()); ok {
+			patterns = append(patterns, p)
+		}
+	}
+	return patterns, scanner.Err()
+}
+
+// gitignore decides which paths under a directory git would ignore, going
+// by .git/info/exclude, the .gitignore files of the directory and its
+// subdirectories, and a .ddiffignore file at the top for patterns that
+// should only apply to ddiff. The .git directory itself is always ignored.
+type gitignore struct {
+	root     string
+	patterns map[string][]ignorePattern // by slash-separated directory, "" for the root
+}
+
+func newGitignore(root string) (*gitignore, error) {
+	g := &gitignore{root: root, patterns: make(map[string][]ignorePattern)}
+	var patterns []ignorePattern
+	for _, name := range []string{".git/info/exclude", ".gitignore", ".ddiffignore"} {
+		more, err := readIgnoreFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, more...)
+	}
+	g.patterns[""] = patterns
+	return g, nil
+}
+
+// dirPatterns returns the patterns of the .gitignore file in dir, reading
+// it the first time it is needed.
+func (g *gitignore) dirPatterns(dir string) ([]ignorePattern, error) {
+	if patterns, ok := g.patterns[dir]; ok {
+		return patterns, nil
+	}
+	patterns, err := readIgnoreFile(filepath.Join(g.root, filepath.FromSlash(dir), ".gitignore"))
+	if err != nil {
+		return nil, err
+	}
+	g.patterns[dir] = patterns
+	return patterns, nil
+}
+
+// ignored reports whether relPath is ignored, assuming the directories
+// containing it are not. Patterns from deeper directories take precedence,
+// and within a file the last matching pattern wins.
+func (g *gitignore) ignored(relPath string, isDir bool) (bool, error) {
+	components := strings.Split(filepath.ToSlash(relPath), "/")
+	if components[len(components)-1] == ".git" {
+		return true, nil
+	}
+	result := false
+	for depth := range components {
+		patterns, err := g.dirPatterns(strings.Join(components[:depth], "/"))
+		if err != nil {
+			return false, err
+		}
+		for _, p := range patterns {
+			if p.match(components[depth:], isDir) {
+				result = !p.negate
+			}
+		}
+	}
+	return result, nil
+}
+
+// ignoredEntry reports whether the entry at relPath is ignored, either
+// itself or because a directory containing it is.
+func (g *gitignore) ignoredEntry(relPath string, isDir bool) (bool, error) {
+	components := strings.Split(filepath.ToSlash(relPath), "/")
+	for i := 1; i <= len(components); i++ {
+		ignored, err := g.ignored(strings.Join(components[:i], "/"), i < len(components) || isDir)
+		if ignored || err != nil {
+			return ignored, err
+		}
+	}
+	return false, nil
+}
+
+// dropIgnored removes from entries those that g ignores. They are all
+// directories if isDir is set, otherwise all files.
+func dropIgnored(entries []string, g *gitignore, isDir bool) ([]string, error) {
+	var kept []string
+	for _, f := range entries {
+		ignored, err := g.ignoredEntry(f, isDir)
+		if err != nil {
+			return nil, err
+		}
+		if !ignored {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
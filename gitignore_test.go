@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseIgnoreLine(t *testing.T) {
+	cases := []struct {
+		line string
+		ok   bool
+		want ignorePattern
+	}{
+		{"", false, ignorePattern{}},
+		{"# comment", false, ignorePattern{}},
+		{"\\#file", true, ignorePattern{segments: []string{"\\#file"}}},
+		{"*.log  ", true, ignorePattern{segments: []string{"*.log"}}},
+		{"!keep.log", true, ignorePattern{segments: []string{"keep.log"}, negate: true}},
+		{"build/", true, ignorePattern{segments: []string{"build"}, dirOnly: true}},
+		{"/todo", true, ignorePattern{segments: []string{"todo"}, anchored: true}},
+		{"doc/*.txt", true, ignorePattern{segments: []string{"doc", "*.txt"}, anchored: true}},
+		{"[!a]", true, ignorePattern{segments: []string{"[^a]"}}},
+	}
+	for _, c := range cases {
+		got, ok := parseIgnoreLine(c.line)
+		if ok != c.ok || strings.Join(got.segments, "/") != strings.Join(c.want.segments, "/") ||
+			got.negate != c.want.negate || got.dirOnly != c.want.dirOnly || got.anchored != c.want.anchored {
+			t.Errorf("parseIgnoreLine(%q) = %+v, %v; want %+v, %v", c.line, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestIgnorePatternMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a/b/debug.log", false, true},
+		{"/todo", "todo", false, true},
+		{"/todo", "sub/todo", false, false},
+		{"doc/*.txt", "doc/notes.txt", false, true},
+		{"doc/*.txt", "doc/server/arch.txt", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"**/foo", "a/b/foo", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"abc/**", "abc/x", false, true},
+		{"abc/**", "abc", true, false},
+	}
+	for _, c := range cases {
+		p, _ := parseIgnoreLine(c.pattern)
+		if got := p.match(strings.Split(c.path, "/"), c.isDir); got != c.want {
+			t.Errorf("%q matching %q: got %v, want %v", c.pattern, c.path, got, c.want)
+		}
+	}
+}
+
+func TestGitignore(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".git/info/exclude": "secret.txt\n",
+		".gitignore":        "*.log\n!keep.log\n/out/\n",
+		".ddiffignore":      "*.snap\n",
+		"src/.gitignore":    "!debug.log\ngenerated/\n",
+	})
+	g, err := newGitignore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		path string
+		want bool
+	}{
+		{"main.go", false},
+		{"secret.txt", true},
+		{"x.snap", true},
+		{"app.log", true},
+		{"keep.log", false},
+		{"src/app.log", true},
+		{"src/debug.log", false}, // re-included by the deeper file
+		{"out/bin", true},
+		{"src/out/bin", false}, // /out/ is anchored to the top
+		{"src/generated/x.go", true},
+		{".git/config", true},
+	}
+	for _, c := range cases {
+		got, err := g.ignoredEntry(c.path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("ignoredEntry(%q) = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+func TestCLIRespectGitignore(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{
+		".gitignore":   "node_modules/\n",
+		"main.go":      "package main\n",
+		"app.log":      "old log\n",
+		"node_modules": "",
+	})
+	writeTree(t, b, map[string]string{
+		".gitignore":               "node_modules/\n",
+		".ddiffignore":             "*.log\n",
+		"main.go":                  "package main\n",
+		"app.log":                  "new log\n",
+		"node_modules/pkg/main.js": "x\n",
+	})
+
+	output, err := exec.Command("./ddiff", "--respect-gitignore", a, b).CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("expected differences, got %v:\n%s", err, output)
+	}
+	// app.log is ignored by b alone, but is skipped on both sides. a has a
+	// plain file where b has an ignored directory, so it is still compared.
+	if strings.Contains(string(output), "log") {
+		t.Errorf("expected app.log to be skipped, got\n%s", output)
+	}
+	if strings.Contains(string(output), "main.js") {
+		t.Errorf("expected node_modules to be skipped, got\n%s", output)
+	}
+
+	output, err = exec.Command("./ddiff", a, b).CombinedOutput()
+	if exitCode(err) != exitDifferent || !strings.Contains(string(output), "+new log") {
+		t.Errorf("expected ignore files to be disregarded without the flag, got %v:\n%s", err, output)
+	}
+}
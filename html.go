@@ -0,0 +1,290 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// printHTMLHeader starts the standalone page written by --format=html. The
+// files follow as they are compared, and printHTMLFooter closes the page;
+// the file tree and totals are filled in by the page's script, so nothing
+// has to be held back until the end.
+func printHTMLHeader(path1, path2 string) {
+	title := html.EscapeString(path1 + " → " + path2)
+	fmt.Printf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="generator" content="ddiff">
+<title>ddiff: %s</title>
+<style>
+%s</style>
+</head>
+<body class="unified">
+<header>
+<h1>%s</h1>
+<p id="summary"></p>
+<div class="toolbar">
+<button type="button" data-view="unified" class="active">Unified</button>
+<button type="button" data-view="split">Side by side</button>
+<button type="button" id="expand-all">Expand all</button>
+<button type="button" id="collapse-all">Collapse all</button>
+</div>
+</header>
+<nav id="tree"></nav>
+<main id="files">
+`, title, htmlStyle, title)
+}
+
+// printHTMLFooter ends the page begun by printHTMLHeader.
+func printHTMLFooter() {
+	fmt.Printf("</main>\n<script>\n%s</script>\n</body>\n</html>\n", htmlScript)
+}
+
+// printHTMLFile writes the collapsible section for one file, holding both
+// a unified and a side-by-side table; the page shows one at a time.
+func printHTMLFile(record jsonFile, config Config) {
+	path := record.NewPath
+	if path == "" {
+		path = record.OldPath
+	}
+	insertions, deletions := 0, 0
+	for _, hunk := range record.Hunks {
+		for _, line := range hunk.Lines {
+			switch line.Op {
+			case "insert":
+				insertions++
+			case "delete":
+				deletions++
+			}
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<details class=\"file\" open data-path=\"%s\" data-status=\"%s\" data-insertions=\"%d\" data-deletions=\"%d\">\n",
+		html.EscapeString(path), record.Status, insertions, deletions)
+	fmt.Fprintf(&b, "<summary><span class=\"status %s\">%s</span> <span class=\"name\">%s</span>",
+		record.Status, record.Status, html.EscapeString(path))
+	if insertions > 0 || deletions > 0 {
+		fmt.Fprintf(&b, " <span class=\"counts\"><span class=\"ins\">+%d</span> <span class=\"del\">&minus;%d</span></span>", insertions, deletions)
+	}
+	b.WriteString("</summary>\n")
+
+	switch record.Status {
+	case "renamed", "copied":
+		fmt.Fprintf(&b, "<p class=\"note\">%s from %s (similarity %d%%)</p>\n",
+			record.Status, html.EscapeString(record.OldPath), record.Similarity)
+	case "binary":
+		b.WriteString("<p class=\"note\">Binary files differ</p>\n")
+	case "added":
+		b.WriteString("<p class=\"note\">Only in the new tree</p>\n")
+	case "deleted":
+		b.WriteString("<p class=\"note\">Only in the old tree</p>\n")
+	case "type-changed":
+		fmt.Fprintf(&b, "<p class=\"note\">Changed from a %s to a %s</p>\n", record.OldType, record.NewType)
+	case "symlink-changed":
+		fmt.Fprintf(&b, "<p class=\"note\">Link target changed from %s to %s</p>\n",
+			html.EscapeString(record.OldTarget), html.EscapeString(record.NewTarget))
+	}
+	if record.OldMode != record.NewMode {
+		fmt.Fprintf(&b, "<p class=\"note\">Mode changed from %s to %s</p>\n", record.OldMode, record.NewMode)
+	}
+
+	if len(record.Hunks) > 0 {
+		chars := config.intraline == "char"
+		highlight := config.intraline != "none"
+		var unified, split strings.Builder
+		for _, hunk := range record.Hunks {
+			header := html.EscapeString(hunk.header())
+			fmt.Fprintf(&unified, "<tr class=\"hunk\"><td colspan=\"3\">%s</td></tr>\n", header)
+			fmt.Fprintf(&split, "<tr class=\"hunk\"><td colspan=\"4\">%s</td></tr>\n", header)
+			writeHTMLHunk(&unified, &split, hunk.Lines, highlight, chars)
+		}
+		fmt.Fprintf(&b, "<table class=\"diff unified-view\">\n%s</table>\n", unified.String())
+		fmt.Fprintf(&b, "<table class=\"diff split-view\">\n%s</table>\n", split.String())
+	}
+	b.WriteString("</details>\n")
+	fmt.Print(b.String())
+}
+
+// writeHTMLHunk adds the rows for one hunk's lines to both tables. Each run
+// of deleted lines is paired with the inserted lines that follow it, side
+// by side and, when highlight is set, with the changed words marked.
+func writeHTMLHunk(unified, split *strings.Builder, lines []jsonLine, highlight, chars bool) {
+	cells := make([]string, len(lines))
+	for i, line := range lines {
+		cells[i] = html.EscapeString(line.Text)
+	}
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == "context" {
+			line := lines[i]
+			fmt.Fprintf(unified, "<tr class=\"context\"><td class=\"num\">%d</td><td class=\"num\">%d</td><td class=\"code\"> %s</td></tr>\n",
+				line.OldLine, line.NewLine, cells[i])
+			fmt.Fprintf(split, "<tr class=\"context\"><td class=\"num\">%d</td><td class=\"code\">%s</td><td class=\"num\">%d</td><td class=\"code\">%s</td></tr>\n",
+				line.OldLine, cells[i], line.NewLine, cells[i])
+			i++
+			continue
+		}
+
+		var deleted, inserted []int
+		for ; i < len(lines) && lines[i].Op == "delete"; i++ {
+			deleted = append(deleted, i)
+		}
+		for ; i < len(lines) && lines[i].Op == "insert"; i++ {
+			inserted = append(inserted, i)
+		}
+		if highlight {
+			for k := 0; k < min(len(deleted), len(inserted)); k++ {
+				d, n := deleted[k], inserted[k]
+				cells[d], cells[n] = highlightHTML(lines[d].Text, lines[n].Text, chars)
+			}
+		}
+
+		for _, d := range deleted {
+			fmt.Fprintf(unified, "<tr class=\"del\"><td class=\"num\">%d</td><td class=\"num\"></td><td class=\"code\">-%s</td></tr>\n",
+				lines[d].OldLine, cells[d])
+		}
+		for _, n := range inserted {
+			fmt.Fprintf(unified, "<tr class=\"ins\"><td class=\"num\"></td><td class=\"num\">%d</td><td class=\"code\">+%s</td></tr>\n",
+				lines[n].NewLine, cells[n])
+		}
+		for k := 0; k < max(len(deleted), len(inserted)); k++ {
+			split.WriteString("<tr>")
+			if k < len(deleted) {
+				fmt.Fprintf(split, "<td class=\"num del\">%d</td><td class=\"code del\">%s</td>", lines[deleted[k]].OldLine, cells[deleted[k]])
+			} else {
+				split.WriteString("<td class=\"num empty\"></td><td class=\"code empty\"></td>")
+			}
+			if k < len(inserted) {
+				fmt.Fprintf(split, "<td class=\"num ins\">%d</td><td class=\"code ins\">%s</td>", lines[inserted[k]].NewLine, cells[inserted[k]])
+			} else {
+				split.WriteString("<td class=\"num empty\"></td><td class=\"code empty\"></td>")
+			}
+			split.WriteString("</tr>\n")
+		}
+	}
+}
+
+// highlightHTML escapes a pair of changed lines for the page, marking the
+// parts that differ as highlightPair does for the terminal.
+func highlightHTML(old, new string, chars bool) (string, string) {
+	spans := diffTokens(old, new, chars)
+	if !worthHighlighting(spans) {
+		return html.EscapeString(old), html.EscapeString(new)
+	}
+	var b1, b2 strings.Builder
+	for _, span := range spans {
+		text := html.EscapeString(span.text)
+		switch span.op {
+		case ' ':
+			b1.WriteString(text)
+			b2.WriteString(text)
+		case '-':
+			b1.WriteString("<mark>" + text + "</mark>")
+		case '+':
+			b2.WriteString("<mark>" + text + "</mark>")
+		}
+	}
+	return b1.String(), b2.String()
+}
+
+const htmlStyle = `body { margin: 0; font: 14px/1.4 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; display: grid; grid-template-columns: 260px 1fr; grid-template-rows: auto 1fr; }
+header { grid-column: 1 / 3; padding: 12px 16px; border-bottom: 1px solid #d0d7de; background: #f6f8fa; }
+h1 { font-size: 18px; margin: 0 0 4px; }
+#summary { margin: 0 0 8px; color: #59636e; }
+.toolbar button { font: inherit; padding: 3px 10px; border: 1px solid #d0d7de; border-radius: 6px; background: #fff; cursor: pointer; }
+.toolbar button.active { background: #0969da; border-color: #0969da; color: #fff; }
+nav { padding: 8px; border-right: 1px solid #d0d7de; overflow: auto; font-size: 13px; }
+nav ul { list-style: none; margin: 0; padding-left: 14px; }
+nav > ul { padding-left: 0; }
+nav a { color: inherit; text-decoration: none; }
+nav a:hover { text-decoration: underline; }
+nav .dir { color: #59636e; }
+main { padding: 12px 16px; min-width: 0; }
+details.file { border: 1px solid #d0d7de; border-radius: 6px; margin-bottom: 16px; overflow: hidden; }
+details.file > summary { padding: 6px 10px; background: #f6f8fa; cursor: pointer; font-family: ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+.status { display: inline-block; min-width: 64px; font-size: 12px; text-align: center; border-radius: 10px; padding: 0 6px; color: #fff; background: #59636e; }
+.status.modified { background: #9a6700; }
+.status.added { background: #1a7f37; }
+.status.deleted { background: #cf222e; }
+.status.renamed, .status.copied { background: #8250df; }
+.status.mode-changed, .status.type-changed, .status.symlink-changed { background: #0969da; }
+.counts .ins { color: #1a7f37; }
+.counts .del { color: #cf222e; }
+.note { margin: 0; padding: 6px 10px; color: #59636e; }
+table.diff { width: 100%; border-collapse: collapse; table-layout: fixed; font: 12px/1.5 ui-monospace, SFMono-Regular, Menlo, Consolas, monospace; }
+table.diff td { padding: 0 8px; vertical-align: top; }
+td.num { width: 48px; text-align: right; color: #59636e; user-select: none; }
+td.code { white-space: pre-wrap; word-break: break-all; tab-size: 4; }
+tr.hunk td { background: #ddf4ff; color: #59636e; padding: 2px 8px; }
+tr.del td, td.del { background: #ffebe9; }
+tr.ins td, td.ins { background: #e6ffec; }
+td.empty { background: #f6f8fa; }
+tr.del mark, td.del mark { background: #ff818266; }
+tr.ins mark, td.ins mark { background: #4ac26b66; }
+mark { color: inherit; border-radius: 2px; }
+body.unified .split-view, body.split .unified-view { display: none; }
+`
+
+const htmlScript = `(function () {
+  var files = Array.prototype.slice.call(document.querySelectorAll("details.file"));
+  var insertions = 0, deletions = 0;
+  var root = {dirs: {}, files: []};
+  files.forEach(function (file, i) {
+    file.id = "file-" + (i + 1);
+    insertions += +file.dataset.insertions;
+    deletions += +file.dataset.deletions;
+    var parts = file.dataset.path.split("/");
+    var node = root;
+    for (var j = 0; j < parts.length - 1; j++) {
+      node = node.dirs[parts[j]] = node.dirs[parts[j]] || {dirs: {}, files: []};
+    }
+    node.files.push({name: parts[parts.length - 1], file: file});
+  });
+
+  function build(node) {
+    var ul = document.createElement("ul");
+    Object.keys(node.dirs).sort().forEach(function (name) {
+      var li = document.createElement("li");
+      var label = document.createElement("span");
+      label.className = "dir";
+      label.textContent = name + "/";
+      li.appendChild(label);
+      li.appendChild(build(node.dirs[name]));
+      ul.appendChild(li);
+    });
+    node.files.forEach(function (entry) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = "#" + entry.file.id;
+      a.textContent = entry.name;
+      a.title = entry.file.dataset.status;
+      a.addEventListener("click", function () { entry.file.open = true; });
+      li.appendChild(a);
+      ul.appendChild(li);
+    });
+    return ul;
+  }
+  document.getElementById("tree").appendChild(build(root));
+
+  document.getElementById("summary").textContent = files.length + (files.length === 1 ? " file" : " files") +
+    " changed, " + insertions + " insertions(+), " + deletions + " deletions(-)";
+
+  var buttons = document.querySelectorAll("button[data-view]");
+  Array.prototype.forEach.call(buttons, function (button) {
+    button.addEventListener("click", function () {
+      document.body.className = button.dataset.view;
+      Array.prototype.forEach.call(buttons, function (b) { b.classList.toggle("active", b === button); });
+    });
+  });
+  document.getElementById("expand-all").addEventListener("click", function () {
+    files.forEach(function (file) { file.open = true; });
+  });
+  document.getElementById("collapse-all").addEventListener("click", function () {
+    files.forEach(function (file) { file.open = false; });
+  });
+})();
+`
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHighlightHTML(t *testing.T) {
+	old, new := highlightHTML("if a < b {", "if a <= b {", false)
+	if old != "if a &lt; b {" || new != "if a &lt;<mark>=</mark> b {" {
+		t.Errorf("unexpected highlighting %q, %q", old, new)
+	}
+}
+
+func TestJSONHunkHeader(t *testing.T) {
+	cases := []struct {
+		hunk jsonHunk
+		want string
+	}{
+		{jsonHunk{OldStart: 1, OldLines: 5, NewStart: 1, NewLines: 6}, "@@ -1,5 +1,6 @@"},
+		{jsonHunk{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 2}, "@@ -0,0 +1,2 @@"},
+		{jsonHunk{OldStart: 3, OldLines: 1, NewStart: 2, NewLines: 0}, "@@ -3 +2,0 @@"},
+	}
+	for _, c := range cases {
+		if got := c.hunk.header(); got != c.want {
+			t.Errorf("%+v: expected %q, got %q", c.hunk, c.want, got)
+		}
+	}
+}
+
+func TestCLIHTML(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{
+		"src/main.go": "package main\n\nfunc main() {\n\tif a < b {\n\t}\n}\n",
+		"gone.txt":    "bye\n",
+	})
+	writeTree(t, filepath.Join(dir, "b"), map[string]string{
+		"src/main.go": "package main\n\nfunc main() {\n\tif a <= b {\n\t}\n}\n",
+		"new.txt":     "hi\n",
+	})
+
+	output, err := exec.Command("./ddiff", "--format=html", filepath.Join(dir, "a"), filepath.Join(dir, "b")).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	page := string(output)
+	if !strings.HasPrefix(page, "<!DOCTYPE html>") || !strings.HasSuffix(page, "</html>\n") {
+		t.Fatalf("expected a complete HTML document, got\n%s", page)
+	}
+
+	sections := regexp.MustCompile(`<details class="file" open data-path="([^"]*)" data-status="([^"]*)"`).FindAllStringSubmatch(page, -1)
+	var got []string
+	for _, s := range sections {
+		got = append(got, s[1]+":"+s[2])
+	}
+	want := []string{"gone.txt:deleted", "new.txt:added", "src/main.go:modified"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected sections %q, got %q", want, got)
+	}
+
+	for _, s := range []string{
+		`class="diff unified-view"`,
+		`class="diff split-view"`,
+		"@@ -1,6 +1,6 @@",
+		"if a &lt;<mark>=</mark> b {",
+	} {
+		if !strings.Contains(page, s) {
+			t.Errorf("expected the page to contain %q", s)
+		}
+	}
+
+	// The page must work offline.
+	if regexp.MustCompile(`(src|href)="(https?:)?//`).MatchString(page) {
+		t.Error("expected no external resources in the page")
+	}
+}
+
+func TestCLIHTMLStats(t *testing.T) {
+	output, err := exec.Command("./ddiff", "--format=html", "--stats", "testdata/deep1", "testdata/deep2").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	// The page totals the changes itself, so no text summary is added
+	page := string(output)
+	if !strings.HasSuffix(page, "</html>\n") {
+		t.Fatalf("expected a complete HTML document, got\n%s", page)
+	}
+	if strings.Contains(page, "files changed") || strings.Contains(page, "| 11 +++") {
+		t.Errorf("expected no text diffstat in the page, got\n%s", page)
+	}
+}
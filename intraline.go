@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ANSI codes that mark the changed part of a line inside its red or green.
+const (
+	emphasisOn  = "\033[7m"
+	emphasisOff = "\033[27m"
+)
+
+// tokenize splits a line into the units intraline diffs work on: with
+// chars, every character; otherwise runs of letters, digits and
+// underscores, runs of whitespace, and single other characters.
+func tokenize(s string, chars bool) []string {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		if !chars {
+			switch {
+			case isWordRune(runes[i]):
+				for j < len(runes) && isWordRune(runes[j]) {
+					j++
+				}
+			case unicode.IsSpace(runes[i]):
+				for j < len(runes) && unicode.IsSpace(runes[j]) {
+					j++
+				}
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenSpan is a run of tokens from one side of a line pair that is either
+// common to both lines or changed.
+type tokenSpan struct {
+	text string
+	op   byte // ' ' common, '-' only in the old line, '+' only in the new
+}
+
+// diffTokens compares two lines token by token and returns the spans that
+// make up the merged line, deletions before insertions.
+func diffTokens(old, new string, chars bool) []tokenSpan {
+	tokens1, tokens2 := tokenize(old, chars), tokenize(new, chars)
+	var spans []tokenSpan
+	for _, edit := range myersDiff(tokens1, tokens2) {
+		switch edit.Type {
+		case "equal":
+			spans = append(spans, tokenSpan{strings.Join(tokens1[edit.Start1:edit.End1], ""), ' '})
+		case "delete":
+			spans = append(spans, tokenSpan{strings.Join(tokens1[edit.Start1:edit.End1], ""), '-'})
+		case "insert":
+			spans = append(spans, tokenSpan{strings.Join(tokens2[edit.Start2:edit.End2], ""), '+'})
+		}
+	}
+	return spans
+}
+
+// worthHighlighting reports whether two lines have enough in common for
+// marking their differences to help; lines that share only whitespace or
+// punctuation are better read as wholly replaced.
+func worthHighlighting(spans []tokenSpan) bool {
+	for _, span := range spans {
+		if span.op == ' ' && strings.IndexFunc(span.text, isWordRune) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightPair returns the old and new line with the parts that differ
+// wrapped in emphasis codes, or the lines unchanged if they have too
+// little in common.
+func highlightPair(old, new string, chars bool) (string, string) {
+	spans := diffTokens(old, new, chars)
+	if !worthHighlighting(spans) {
+		return old, new
+	}
+	var b1, b2 strings.Builder
+	for _, span := range spans {
+		switch span.op {
+		case ' ':
+			b1.WriteString(span.text)
+			b2.WriteString(span.text)
+		case '-':
+			b1.WriteString(emphasisOn + span.text + emphasisOff)
+		case '+':
+			b2.WriteString(emphasisOn + span.text + emphasisOff)
+		}
+	}
+	return b1.String(), b2.String()
+}
+
+// highlightHunkLines finds the runs of deleted lines directly followed by
+// inserted lines in a unified diff and returns emphasized versions of the
+// paired lines, keyed by their index in diff. Only lines after the first
+// hunk header are considered, so file headers are never mistaken for
+// changes.
+func highlightHunkLines(diff []string, chars bool) map[int]string {
+	highlighted := make(map[int]string)
+	inHunk := false
+	for i := 0; i < len(diff); {
+		line := diff[i]
+		if strings.HasPrefix(line, "@@") {
+			inHunk = true
+		}
+		if !inHunk || !strings.HasPrefix(line, "-") {
+			i++
+			continue
+		}
+
+		var deleted, inserted []int
+		for ; i < len(diff) && strings.HasPrefix(diff[i], "-"); i++ {
+			deleted = append(deleted, i)
+		}
+		if i < len(diff) && strings.HasPrefix(diff[i], "\\") {
+			i++
+		}
+		for ; i < len(diff) && strings.HasPrefix(diff[i], "+"); i++ {
+			inserted = append(inserted, i)
+		}
+
+		for k := 0; k < min(len(deleted), len(inserted)); k++ {
+			d, n := deleted[k], inserted[k]
+			old, new := highlightPair(diff[d][1:], diff[n][1:], chars)
+			highlighted[d] = "-" + old
+			highlighted[n] = "+" + new
+		}
+	}
+	return highlighted
+}
+
+// wordDiffModes are the values accepted by --word-diff.
+var wordDiffModes = map[string]bool{"color": true, "plain": true, "porcelain": true}
+
+// printWordDiff prints the groups of a diff as git's --word-diff does:
+// each changed line is paired with its replacement and shown once, with
+// the words that differ marked [-like this-]{+and this+} (plain), in red
+// and green (color), or as one span per line (porcelain).
+func printWordDiff(file1, file2 string, text1, text2 textFile, groups [][]Edit, config Config) {
+	if len(groups) == 0 {
+		return
+	}
+	chars := config.intraline == "char"
+
+	printColor(config, "white", fmt.Sprintf("--- %s\n", file1))
+	printColor(config, "white", fmt.Sprintf("+++ %s\n", file2))
+	for _, group := range groups {
+		start1, end1, start2, end2 := hunkBounds(len(text1.lines), len(text2.lines), group, config.showContext)
+		printColor(config, "cyan", fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(start1, end1), hunkRange(start2, end2)))
+
+		for _, row := range sideBySideRows(text1, text2, group, config.showContext) {
+			var spans []tokenSpan
+			switch row.marker {
+			case ' ':
+				spans = []tokenSpan{{row.left, ' '}}
+			case '<':
+				spans = []tokenSpan{{row.left, '-'}}
+			case '>':
+				spans = []tokenSpan{{row.right, '+'}}
+			default:
+				spans = diffTokens(row.left, row.right, chars)
+			}
+			printWordSpans(spans, config)
+		}
+	}
+}
+
+// printWordSpans prints one line of --word-diff output. As in git, asking
+// for color words turns color on even when the output is not a terminal,
+// since nothing else marks the changes; only --color=false turns it off,
+// and then the plain markup is used instead.
+func printWordSpans(spans []tokenSpan, config Config) {
+	mode := config.wordDiff
+	if mode == "color" && !config.showColors {
+		mode = "plain"
+	}
+	for _, span := range spans {
+		if span.text == "" {
+			continue
+		}
+		switch mode {
+		case "porcelain":
+			fmt.Printf("%c%s\n", span.op, span.text)
+		case "color":
+			switch span.op {
+			case '-':
+				printANSI("red", span.text)
+			case '+':
+				printANSI("green", span.text)
+			default:
+				fmt.Print(span.text)
+			}
+		default:
+			switch span.op {
+			case '-':
+				fmt.Print("[-" + span.text + "-]")
+			case '+':
+				fmt.Print("{+" + span.text + "+}")
+			default:
+				fmt.Print(span.text)
+			}
+		}
+	}
+	if config.wordDiff == "porcelain" {
+		fmt.Println("~")
+	} else {
+		fmt.Println()
+	}
+}
+
+// wordDiffFlag is --word-diff, which like git's defaults to plain when
+// given without a mode.
+type wordDiffFlag struct {
+	mode *string
+}
+
+func (f wordDiffFlag) String() string {
+	if f.mode == nil {
+		return ""
+	}
+	return *f.mode
+}
+
+func (f wordDiffFlag) Set(value string) error {
+	switch {
+	case value == "true":
+		*f.mode = "plain"
+	case value == "false":
+		*f.mode = ""
+	case wordDiffModes[value]:
+		*f.mode = value
+	default:
+		return fmt.Errorf("invalid mode %q: want color, plain or porcelain", value)
+	}
+	return nil
+}
+
+func (f wordDiffFlag) IsBoolFlag() bool {
+	return true
+}
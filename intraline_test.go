@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("foo_bar(x, 42)  +y", false)
+	want := []string{"foo_bar", "(", "x", ",", " ", "42", ")", "  ", "+", "y"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("word tokens: expected %q, got %q", want, got)
+	}
+
+	got = tokenize("héllo", true)
+	want = []string{"h", "é", "l", "l", "o"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("character tokens: expected %q, got %q", want, got)
+	}
+}
+
+func TestDiffTokens(t *testing.T) {
+	spans := diffTokens("x := compute(a, b)", "x := compute(a, c)", false)
+	var got []string
+	for _, span := range spans {
+		got = append(got, string(span.op)+span.text)
+	}
+	want := []string{" x := compute(a, ", "-b", "+c", " )"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected spans %q, got %q", want, got)
+	}
+}
+
+func TestHighlightPair(t *testing.T) {
+	old, new := highlightPair("total = price * count", "total = price * quantity", false)
+	if old != "total = price * "+emphasisOn+"count"+emphasisOff {
+		t.Errorf("unexpected old line %q", old)
+	}
+	if new != "total = price * "+emphasisOn+"quantity"+emphasisOff {
+		t.Errorf("unexpected new line %q", new)
+	}
+
+	old, new = highlightPair("color", "colour", true)
+	if new != "colo"+emphasisOn+"u"+emphasisOff+"r" || old != "color" {
+		t.Errorf("unexpected character highlighting %q, %q", old, new)
+	}
+
+	// Lines with nothing but punctuation in common are left alone.
+	old, new = highlightPair("alpha();", "beta();", false)
+	if old != "alpha();" || new != "beta();" {
+		t.Errorf("expected unrelated lines unchanged, got %q, %q", old, new)
+	}
+}
+
+func TestHighlightHunkLines(t *testing.T) {
+	diff := []string{
+		"--- a.txt",
+		"+++ a.txt",
+		"@@ -1,3 +1,2 @@",
+		" same",
+		"-one two",
+		"-gone",
+		"+one three",
+		" end",
+	}
+	highlighted := highlightHunkLines(diff, false)
+	if len(highlighted) != 2 {
+		t.Fatalf("expected only the paired lines highlighted, got %q", highlighted)
+	}
+	if highlighted[4] != "-one "+emphasisOn+"two"+emphasisOff || highlighted[6] != "+one "+emphasisOn+"three"+emphasisOff {
+		t.Errorf("unexpected highlighting %q", highlighted)
+	}
+}
+
+func TestWordDiffFlag(t *testing.T) {
+	var mode string
+	f := wordDiffFlag{&mode}
+	for value, want := range map[string]string{"true": "plain", "color": "color", "porcelain": "porcelain", "false": ""} {
+		if err := f.Set(value); err != nil || mode != want {
+			t.Errorf("Set(%q): got %q, %v; want %q", value, mode, err, want)
+		}
+	}
+	if err := f.Set("bogus"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestCLIWordDiff(t *testing.T) {
+	output, err := exec.Command("./ddiff", "--color=false", "--word-diff", "testdata/file1.txt", "testdata/file2.txt").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if !strings.Contains(string(output), "{+modified +}line 2\n") {
+		t.Errorf("expected plain word diff markup, got\n%s", output)
+	}
+
+	output, err = exec.Command("./ddiff", "--word-diff=porcelain", "testdata/file1.txt", "testdata/file2.txt").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if !strings.Contains(string(output), " line 1\n~\n+modified \n line 2\n~\n") {
+		t.Errorf("expected porcelain word diff, got\n%s", output)
+	}
+
+	// Color words are colored even when piped, and fall back to markup when
+	// color is turned off, so that removed and added words never run
+	// together
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"old": "one\ntwo\n", "new": "one\nTWO\n"})
+	old, new := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+	output, err = exec.Command("./ddiff", "--word-diff=color", old, new).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if !strings.Contains(string(output), "\033[31mtwo\033[0m\033[32mTWO\033[0m\n") {
+		t.Errorf("expected colored words, got %q", output)
+	}
+	output, err = exec.Command("./ddiff", "--color=false", "--word-diff=color", old, new).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if !strings.Contains(string(output), "[-two-]{+TWO+}\n") {
+		t.Errorf("expected plain markup without color, got %q", output)
+	}
+
+	if code := exitCode(exec.Command("./ddiff", "--intraline=bogus", "testdata/file1.txt", "testdata/file2.txt").Run()); code != exitTrouble {
+		t.Errorf("expected exit status 2 for an unknown intraline mode, got %d", code)
+	}
+}
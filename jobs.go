@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+)
+
+// sameContent reports whether two files have the same content, going by
+// their sizes and, only when those match, their hashes.
+func sameContent(file1, file2 string) (bool, error) {
+	if file1 == stdinName || file2 == stdinName {
+		return false, nil
+	}
+	info1, err := os.Stat(file1)
+	if err != nil {
+		return false, err
+	}
+	info2, err := os.Stat(file2)
+	if err != nil {
+		return false, err
+	}
+	if info1.Size() != info2.Size() || !info1.Mode().IsRegular() || !info2.Mode().IsRegular() {
+		return false, nil
+	}
+
+	hash1, err := fileHash(file1)
+	if err != nil {
+		return false, err
+	}
+	hash2, err := fileHash(file2)
+	if err != nil {
+		return false, err
+	}
+	return hash1 == hash2, nil
+}
+
+func fileHash(name string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	file, err := os.Open(name)
+	if err != nil {
+		return sum, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// pairQueue compares file pairs on a pool of worker goroutines while the
+// results are consumed in their original order, so output does not depend
+// on which comparison finishes first. Workers run at most a few pairs
+// ahead of the consumer, which bounds the memory held by loaded files.
+type pairQueue struct {
+	pairs   []filePair
+	errs    []error
+	done    []chan struct{}
+	pending chan struct{} // one token per pair started but not yet taken
+}
+
+// lookahead is how many pairs each worker may compare ahead of the one
+// being printed.
+const lookahead = 4
+
+// startPairs begins comparing files1[i] with files2[i] for each i, using
+// up to jobs workers. Every pair must then be taken with wait, in order.
+func startPairs(files1, files2 []string, jobs int, config Config) *pairQueue {
+	q := &pairQueue{
+		pairs:   make([]filePair, len(files1)),
+		errs:    make([]error, len(files1)),
+		done:    make([]chan struct{}, len(files1)),
+		pending: make(chan struct{}, jobs*lookahead),
+	}
+	for i := range q.done {
+		q.done[i] = make(chan struct{})
+	}
+
+	work := make(chan int)
+	go func() {
+		for i := range files1 {
+			q.pending <- struct{}{}
+			work <- i
+		}
+		close(work)
+	}()
+	for w := 0; w < jobs; w++ {
+		go func() {
+			for i := range work {
+				q.pairs[i], q.errs[i] = loadFilePair(files1[i], files2[i], config)
+				close(q.done[i])
+			}
+		}()
+	}
+	return q
+}
+
+// wait returns the comparison of pair i once it is ready.
+func (q *pairQueue) wait(i int) (filePair, error) {
+	<-q.done[i]
+	<-q.pending
+	pair, err := q.pairs[i], q.errs[i]
+	q.pairs[i] = filePair{}
+	return pair, err
+}
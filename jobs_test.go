@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestSameContent(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a":      "same\n",
+		"b":      "same\n",
+		"c":      "diff\n",
+		"longer": "same and more\n",
+		"sub/x":  "",
+	})
+	cases := []struct {
+		file1, file2 string
+		want         bool
+	}{
+		{"a", "b", true},
+		{"a", "c", false},
+		{"a", "longer", false},
+		{"sub", "sub", false}, // directories are never taken as identical
+	}
+	for _, c := range cases {
+		got, err := sameContent(filepath.Join(dir, c.file1), filepath.Join(dir, c.file2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("sameContent(%s, %s) = %v, want %v", c.file1, c.file2, got, c.want)
+		}
+	}
+}
+
+func TestLoadFilePairSkipsIdenticalFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "x\ny\n", "b": "x\ny\n"})
+	pair, err := loadFilePair(filepath.Join(dir, "a"), filepath.Join(dir, "b"), Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.text1.lines != nil || pair.groups != nil {
+		t.Errorf("expected identical files to be neither read nor diffed, got %+v", pair)
+	}
+}
+
+func TestPairQueueKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+	var files1, files2 []string
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("f%02d", i)
+		writeTree(t, dir, map[string]string{
+			name + ".old": fmt.Sprintf("%d\n", i),
+			name + ".new": fmt.Sprintf("%d\n", i*(i%3)),
+		})
+		files1 = append(files1, filepath.Join(dir, name+".old"))
+		files2 = append(files2, filepath.Join(dir, name+".new"))
+	}
+	files1 = append(files1, filepath.Join(dir, "missing"))
+	files2 = append(files2, filepath.Join(dir, "missing"))
+
+	queue := startPairs(files1, files2, 3, Config{})
+	for i := range files1 {
+		pair, err := queue.wait(i)
+		if i == len(files1)-1 {
+			if err == nil {
+				t.Error("expected an error for a missing file")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		changed := len(pair.groups) > 0
+		if want := i*(i%3) != i; changed != want {
+			t.Errorf("pair %d: changed = %v, want %v", i, changed, want)
+		}
+		if changed && pair.text1.lines[0] != fmt.Sprint(i) {
+			t.Errorf("pair %d: got the result for %q", i, pair.text1.lines[0])
+		}
+	}
+}
+
+func TestCLIJobsOutputMatchesSequential(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	for i := 0; i < 40; i++ {
+		name := fmt.Sprintf("d%d/file%02d.txt", i%4, i)
+		writeTree(t, a, map[string]string{name: fmt.Sprintf("line\n%d\nend\n", i)})
+		if i%5 != 0 {
+			writeTree(t, b, map[string]string{name: fmt.Sprintf("line\n%d\nend\n", i%7)})
+		}
+	}
+
+	sequential, err := exec.Command("./ddiff", "-c=false", "-s", "-j", "1", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	parallel, err := exec.Command("./ddiff", "-c=false", "-s", "--jobs=8", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if string(parallel) != string(sequential) {
+		t.Errorf("expected the same output with 8 jobs as with 1:\n%s\ngot\n%s", sequential, parallel)
+	}
+
+	_, err = exec.Command("./ddiff", "-j", "0", a, b).Output()
+	if exitCode(err) != exitTrouble {
+		t.Errorf("expected -j 0 to be rejected, got %v", err)
+	}
+}
+
+func BenchmarkCompareIdenticalTrees(b *testing.B) {
+	dir := b.TempDir()
+	for i := 0; i < 200; i++ {
+		content := []byte(fmt.Sprintf("%d\n", i))
+		for _, side := range []string{"a", "b"} {
+			path := filepath.Join(dir, side, fmt.Sprintf("%03d.txt", i))
+			os.MkdirAll(filepath.Dir(path), 0755)
+			os.WriteFile(path, content, 0644)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		compareDirs(filepath.Join(dir, "a"), filepath.Join(dir, "b"), Config{recursive: true, jobs: 4})
+	}
+}
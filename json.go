@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// outputFormats are the values accepted by --format.
+var outputFormats = map[string]bool{"unified": true, "context": true, "normal": true, "ed": true, "rcs": true, "json": true, "html": true}
+
+// structured reports whether the output format describes each file with a
+// record built by jsonRecord rather than as text.
+func structured(config Config) bool {
+	return config.format == "json" || config.format == "html"
+}
+
+// printRecord writes the record for one file in the structured format.
+func printRecord(record jsonFile, config Config) {
+	if config.format == "html" {
+		printHTMLFile(record, config)
+	} else {
+		printJSON(record)
+	}
+}
+
+// jsonFile is the record --format=json writes for each file that differs,
+// one per line.
+type jsonFile struct {
+	OldPath    string     `json:"old_path,omitempty"`
+	NewPath    string     `json:"new_path,omitempty"`
+	Status     string     `json:"status"` // modified, added, deleted, binary, renamed, copied, mode-changed, type-changed or symlink-changed
+	Similarity int        `json:"similarity,omitempty"`
+	OldMode    string     `json:"old_mode,omitempty"` // octal permissions, when they changed
+	NewMode    string     `json:"new_mode,omitempty"`
+	OldType    string     `json:"old_type,omitempty"` // entry types, when they differ
+	NewType    string     `json:"new_type,omitempty"`
+	OldTarget  string     `json:"old_target,omitempty"` // symbolic link targets, when they differ
+	NewTarget  string     `json:"new_target,omitempty"`
+	Hunks      []jsonHunk `json:"hunks,omitempty"`
+}
+
+// jsonHunk uses the numbering of a unified diff hunk header: an empty range
+// starts at the line before it.
+type jsonHunk struct {
+	OldStart int        `json:"old_start"`
+	OldLines int        `json:"old_lines"`
+	NewStart int        `json:"new_start"`
+	NewLines int        `json:"new_lines"`
+	Lines    []jsonLine `json:"lines"`
+}
+
+// header returns the hunk's unified diff header.
+func (h jsonHunk) header() string {
+	// Undo jsonRangeStart to get back the 0-based start of each range.
+	start1 := h.OldStart - min(h.OldLines, 1)
+	start2 := h.NewStart - min(h.NewLines, 1)
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(start1, start1+h.OldLines), hunkRange(start2, start2+h.NewLines))
+}
+
+// jsonLine is one line of a hunk. The line numbers are 1-based and present
+// for the sides the line belongs to. eol is omitted when it is "\n".
+type jsonLine struct {
+	Op      string  `json:"op"` // context, delete or insert
+	OldLine int     `json:"old_line,omitempty"`
+	NewLine int     `json:"new_line,omitempty"`
+	Text    string  `json:"text"`
+	EOL     *string `json:"eol,omitempty"`
+}
+
+// jsonRecord builds the record for two text files from their hunk groups.
+func jsonRecord(label1, label2 string, text1, text2 textFile, groups [][]Edit, r *rename, config Config) jsonFile {
+	record := jsonFile{OldPath: label1, NewPath: label2, Status: "modified"}
+	if r != nil {
+		record.Status, record.Similarity = "renamed", r.similarity
+		if r.copy {
+			record.Status = "copied"
+		}
+	}
+
+	for _, group := range groups {
+		start1, end1, start2, end2 := hunkBounds(len(text1.lines), len(text2.lines), group, config.showContext)
+		hunk := jsonHunk{
+			OldStart: jsonRangeStart(start1, end1), OldLines: end1 - start1,
+			NewStart: jsonRangeStart(start2, end2), NewLines: end2 - start2,
+		}
+		line := func(op string, text textFile, i, oldLine, newLine int) {
+			l := jsonLine{Op: op, OldLine: oldLine, NewLine: newLine, Text: text.lines[i]}
+			if eol := text.eol(i); eol != "\n" {
+				l.EOL = &eol
+			}
+			hunk.Lines = append(hunk.Lines, l)
+		}
+		context := func(from1, to1, from2 int) {
+			for i := from1; i < to1; i++ {
+				j := from2 + i - from1
+				line("context", text1, i, i+1, j+1)
+			}
+		}
+
+		context(start1, group[0].Start1, start2)
+		for _, edit := range group {
+			switch edit.Type {
+			case "equal":
+				context(edit.Start1, edit.End1, edit.Start2)
+			case "delete":
+				for i := edit.Start1; i < edit.End1; i++ {
+					line("delete", text1, i, i+1, 0)
+				}
+			case "insert":
+				for j := edit.Start2; j < edit.End2; j++ {
+					line("insert", text2, j, 0, j+1)
+				}
+			}
+		}
+		last := group[len(group)-1]
+		context(last.End1, end1, last.End2)
+
+		record.Hunks = append(record.Hunks, hunk)
+	}
+	return record
+}
+
+// jsonRangeStart numbers the lines [start, end) the way hunkRange does.
+func jsonRangeStart(start, end int) int {
+	if end == start {
+		return start
+	}
+	return start + 1
+}
+
+// printJSON writes v as one line of JSON.
+func printJSON(v any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+}
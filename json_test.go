@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// decodeJSONLines parses ddiff's --format=json output.
+func decodeJSONLines(t *testing.T, output []byte) []jsonFile {
+	t.Helper()
+	var records []jsonFile
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		var record jsonFile
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestJSONRecord(t *testing.T) {
+	text1 := splitLines("a\nb\nc\nd\ne\nf\n")
+	text2 := splitLines("a\nB\r\nc\nd\ne\nf\ng")
+	config := Config{showContext: 1}
+	record := jsonRecord("x", "y", text1, text2, diffGroups(text1, text2, config), nil, config)
+
+	if record.Status != "modified" || len(record.Hunks) != 2 {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	h := record.Hunks[0]
+	if h.OldStart != 1 || h.OldLines != 3 || h.NewStart != 1 || h.NewLines != 3 {
+		t.Errorf("unexpected first hunk ranges %+v", h)
+	}
+	want := []jsonLine{
+		{Op: "context", OldLine: 1, NewLine: 1, Text: "a"},
+		{Op: "delete", OldLine: 2, Text: "b"},
+		{Op: "insert", NewLine: 2, Text: "B"},
+		{Op: "context", OldLine: 3, NewLine: 3, Text: "c"},
+	}
+	if len(h.Lines) != len(want) {
+		t.Fatalf("expected %d lines, got %+v", len(want), h.Lines)
+	}
+	for i := range want {
+		got := h.Lines[i]
+		got.EOL = nil
+		if got != want[i] {
+			t.Errorf("line %d: expected %+v, got %+v", i, want[i], got)
+		}
+	}
+	if h.Lines[2].EOL == nil || *h.Lines[2].EOL != "\r\n" {
+		t.Errorf("expected the CRLF terminator to be recorded, got %+v", h.Lines[2])
+	}
+
+	// The second hunk appends a line without a terminator.
+	h = record.Hunks[1]
+	if h.OldStart != 6 || h.OldLines != 1 || h.NewStart != 6 || h.NewLines != 2 {
+		t.Errorf("unexpected second hunk ranges %+v", h)
+	}
+	if last := h.Lines[len(h.Lines)-1]; last.Op != "insert" || last.EOL == nil || *last.EOL != "" {
+		t.Errorf("expected an unterminated inserted line, got %+v", last)
+	}
+}
+
+func TestCLIJSON(t *testing.T) {
+	output, err := exec.Command("./ddiff", "--format=json", "testdata/dir1", "testdata/dir2").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	records := decodeJSONLines(t, output)
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %+v", records)
+	}
+	if records[0].Status != "deleted" || records[0].OldPath != "only_in_dir1.txt" || records[0].NewPath != "" {
+		t.Errorf("unexpected deleted record %+v", records[0])
+	}
+	if records[1].Status != "added" || records[1].NewPath != "only_in_dir2.txt" {
+		t.Errorf("unexpected added record %+v", records[1])
+	}
+	if records[2].Status != "modified" || records[2].OldPath != "shared.txt" || len(records[2].Hunks) != 1 {
+		t.Errorf("unexpected modified record %+v", records[2])
+	}
+
+	// Every line stays a JSON record when statistics are asked for too
+	output, err = exec.Command("./ddiff", "--format=json", "--stats", "testdata/deep1", "testdata/deep2").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if records := decodeJSONLines(t, output); len(records) != 7 {
+		t.Errorf("expected 7 records, got %d:\n%s", len(records), output)
+	}
+
+	dir := t.TempDir()
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{"old.txt": "1\n2\n3\n4\n"})
+	writeTree(t, filepath.Join(dir, "b"), map[string]string{"new.txt": "1\n2\n3\n4\n"})
+	if err := os.WriteFile(filepath.Join(dir, "a", "bin"), []byte("a\x00b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b", "bin"), []byte("a\x00c"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, _ = exec.Command("./ddiff", "--format=json", "-M", filepath.Join(dir, "a"), filepath.Join(dir, "b")).Output()
+	records = decodeJSONLines(t, output)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %+v", records)
+	}
+	if records[0].Status != "binary" || records[0].OldPath != "bin" {
+		t.Errorf("unexpected binary record %+v", records[0])
+	}
+	if r := records[1]; r.Status != "renamed" || r.OldPath != "old.txt" || r.NewPath != "new.txt" || r.Similarity != 100 || len(r.Hunks) != 0 {
+		t.Errorf("unexpected rename record %+v", r)
+	}
+
+	if code := exitCode(exec.Command("./ddiff", "--format=bogus", "testdata/file1.txt", "testdata/file2.txt").Run()); code != exitTrouble {
+		t.Errorf("expected exit status 2 for an unknown format, got %d", code)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// listing reports whether only the names of differing files are wanted,
+// which any Config.listMode asks for instead of how they differ.
+func listing(config Config) bool {
+	return config.listMode != ""
+}
+
+// contentDiffers reports whether two files differ byte for byte, reading
+// only as far as the first difference.
+func contentDiffers(file1, file2 string) (bool, error) {
+	f1, err := os.Open(file1)
+	if err != nil {
+		return false, err
+	}
+	defer f1.Close()
+	f2, err := os.Open(file2)
+	if err != nil {
+		return false, err
+	}
+	defer f2.Close()
+
+	info1, err := f1.Stat()
+	if err != nil {
+		return false, err
+	}
+	info2, err := f2.Stat()
+	if err != nil {
+		return false, err
+	}
+	if info1.Mode().IsRegular() && info2.Mode().IsRegular() && info1.Size() != info2.Size() {
+		return true, nil
+	}
+
+	buf1 := make([]byte, 64*1024)
+	buf2 := make([]byte, 64*1024)
+	for {
+		n1, err1 := io.ReadFull(f1, buf1)
+		n2, err2 := io.ReadFull(f2, buf2)
+		if !bytes.Equal(buf1[:n1], buf2[:n2]) {
+			return true, nil
+		}
+		end1 := err1 == io.EOF || err1 == io.ErrUnexpectedEOF
+		end2 := err2 == io.EOF || err2 == io.ErrUnexpectedEOF
+		if err1 != nil && !end1 {
+			return false, err1
+		}
+		if err2 != nil && !end2 {
+			return false, err2
+		}
+		if end1 || end2 {
+			return end1 != end2, nil
+		}
+	}
+}
+
+// printListed reports a pair of files in the list mode: as GNU diff -q
+// does for brief, and as git diff --name-only or --name-status does for
+// the others, where the status is "M" for modified, including a change of
+// permissions alone, and "R" or "C" with the similarity for a rename or
+// copy. Type changes are reported by compareSpecial.
+func printListed(file1, file2, label1, label2 string, r *rename, contentDiffers bool, config Config) {
+	switch {
+	case config.listMode == "brief" && !contentDiffers:
+		fmt.Printf("Modes of %s and %s differ\n", file1, file2)
+	case config.listMode == "brief":
+		fmt.Printf("Files %s and %s differ\n", file1, file2)
+	case config.listMode == "name-only":
+		fmt.Println(label2)
+	case r != nil:
+		status := "R"
+		if r.copy {
+			status = "C"
+		}
+		fmt.Printf("%s%03d\t%s\t%s\n", status, r.similarity, label1, label2)
+	default:
+		fmt.Printf("M\t%s\n", label2)
+	}
+}
+
+// printListedOneSided reports a file found only in dir, which is the new
+// tree if added.
+func printListedOneSided(dir, relPath string, added bool, config Config) {
+	switch config.listMode {
+	case "brief":
+		printOnlyIn(dir, relPath)
+	case "name-only":
+		fmt.Println(relPath)
+	case "name-status":
+		status := "D"
+		if added {
+			status = "A"
+		}
+		fmt.Printf("%s\t%s\n", status, relPath)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentDiffers(t *testing.T) {
+	big := strings.Repeat("0123456789abcdef", 10000)
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a":        "same\n",
+		"b":        "same\n",
+		"c":        "sama\n",
+		"big":      big,
+		"big-same": big,
+		"big-end":  big[:len(big)-1] + "x",
+	})
+	cases := []struct {
+		file1, file2 string
+		want         bool
+	}{
+		{"a", "b", false},
+		{"a", "c", true},
+		{"big", "big-same", false},
+		{"big", "big-end", true},
+		{"a", "big", true},
+	}
+	for _, c := range cases {
+		got, err := contentDiffers(filepath.Join(dir, c.file1), filepath.Join(dir, c.file2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("contentDiffers(%s, %s) = %v, want %v", c.file1, c.file2, got, c.want)
+		}
+	}
+}
+
+func TestCLIListModes(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{
+		"changed.txt":  "old\n",
+		"same.txt":     "same\n",
+		"gone.txt":     "bye\n",
+		"moved/x.txt":  "a\nb\nc\nd\n",
+		"spaced.txt":   "a  b\n",
+		"link-or-file": "a regular file\n",
+	})
+	writeTree(t, b, map[string]string{
+		"changed.txt":     "new\n",
+		"same.txt":        "same\n",
+		"new.txt":         "hi\n",
+		"elsewhere/x.txt": "a\nb\nc\nd\n",
+		"spaced.txt":      "a b\n",
+	})
+	if err := os.Symlink("same.txt", filepath.Join(b, "link-or-file")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--name-only"}, "changed.txt\nelsewhere/\ngone.txt\nlink-or-file\nmoved/\nnew.txt\nspaced.txt\n"},
+		{[]string{"--name-status", "-M"}, "M\tchanged.txt\nR100\tmoved/x.txt\telsewhere/x.txt\nD\tgone.txt\nT\tlink-or-file\nA\tnew.txt\nM\tspaced.txt\n"},
+		{[]string{"--name-status", "-w"}, "M\tchanged.txt\nA\telsewhere/\nD\tgone.txt\nT\tlink-or-file\nD\tmoved/\nA\tnew.txt\n"},
+		{[]string{"-q"}, "Files " + filepath.Join(a, "changed.txt") + " and " + filepath.Join(b, "changed.txt") + " differ\n" +
+			"Only in " + b + ": elsewhere/\n" +
+			"Only in " + a + ": gone.txt\n" +
+			"File " + filepath.Join(a, "link-or-file") + " is a regular file while file " + filepath.Join(b, "link-or-file") + " is a symbolic link\n" +
+			"Only in " + a + ": moved/\n" +
+			"Only in " + b + ": new.txt\n" +
+			"Files " + filepath.Join(a, "spaced.txt") + " and " + filepath.Join(b, "spaced.txt") + " differ\n"},
+	}
+	for _, c := range cases {
+		args := append(append([]string{}, c.args...), a, b)
+		output, err := exec.Command("./ddiff", args...).Output()
+		if exitCode(err) != exitDifferent {
+			t.Fatalf("%v: CLI command failed: %v", c.args, err)
+		}
+		if string(output) != c.want {
+			t.Errorf("%v: expected\n%s\ngot\n%s", c.args, c.want, output)
+		}
+	}
+
+	output, err := exec.Command("./ddiff", "-q", "--report-identical-files", filepath.Join(a, "same.txt"), filepath.Join(b, "same.txt")).Output()
+	if exitCode(err) != exitSame || !strings.HasSuffix(string(output), "same.txt are identical\n") {
+		t.Errorf("expected identical files to be reported, got %v: %q", err, output)
+	}
+}
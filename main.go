@@ -1,9 +1,10 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
@@ -12,6 +13,13 @@ import (
 	"strings"
 )
 
+// Exit statuses, following GNU diff.
+const (
+	exitSame      = 0
+	exitDifferent = 1
+	exitTrouble   = 2
+)
+
 type Config struct {
 	showColors    bool
 	showContext   int
@@ -19,9 +27,51 @@ type Config struct {
 	showBinary    bool
 	ignoreSpace   bool
 	showStats     bool
+	diffAlgorithm string
+	numStat       bool
+	shortStat     bool
+
+	ignoreSpaceChange   bool
+	ignoreTrailingSpace bool
+	ignoreBlankLines    bool
+	ignoreCRAtEOL       bool
+
+	findRenames int // similarity threshold in percent, 0 to disable
+	findCopies  int
+
+	sideBySide bool
+	width      int // total width of side-by-side output
+
+	intraline string // "word", "char" or "none"
+	wordDiff  string // "", "color", "plain" or "porcelain"
+
+	format string // "unified", "context", "normal", "ed", "rcs", "json" or "html"
+
+	inTree bool // comparing files found by walking two directories
+
+	filter           pathFilter // which files directory comparisons look at
+	respectGitignore bool
+
+	jobs int // files compared in parallel within a directory
+
+	listMode        string // "", "brief", "name-only" or "name-status"
+	reportIdentical bool
+
+	newFile               bool // diff one-sided files against an empty file
+	unidirectionalNewFile bool // the same, but only for files added in the second tree
+
+	git                  bool // git's extended headers
+	srcPrefix, dstPrefix string
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "merge" {
+		os.Exit(runMerge(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "apply" {
+		os.Exit(runApply(os.Args[2:]))
+	}
+	
 	config := Config{}
 	
 	flag.BoolVar(&config.showColors, "color", true, "Show colored output")
@@ -32,13 +82,78 @@ func main() {
 	flag.BoolVar(&config.recursive, "r", true, "Compare directories recursively (short)")
 	flag.BoolVar(&config.showBinary, "binary", false, "Show binary file differences")
 	flag.BoolVar(&config.showBinary, "b", false, "Show binary file differences (short)")
-	flag.BoolVar(&config.ignoreSpace, "ignore-space", false, "Ignore whitespace changes")
-	flag.BoolVar(&config.ignoreSpace, "w", false, "Ignore whitespace changes (short)")
+	flag.BoolVar(&config.ignoreSpace, "ignore-space", false, "Ignore all whitespace when comparing lines")
+	flag.BoolVar(&config.ignoreSpace, "ignore-all-space", false, "Ignore all whitespace when comparing lines")
+	flag.BoolVar(&config.ignoreSpace, "w", false, "Ignore all whitespace when comparing lines (short)")
+	flag.BoolVar(&config.ignoreSpaceChange, "ignore-space-change", false, "Ignore changes in the amount of whitespace")
+	flag.BoolVar(&config.ignoreTrailingSpace, "ignore-trailing-space", false, "Ignore whitespace at end of line")
+	flag.BoolVar(&config.ignoreTrailingSpace, "Z", false, "Ignore whitespace at end of line (short)")
+	flag.BoolVar(&config.ignoreBlankLines, "ignore-blank-lines", false, "Ignore changes that only insert or delete blank lines")
+	flag.BoolVar(&config.ignoreBlankLines, "B", false, "Ignore changes that only insert or delete blank lines (short)")
+	flag.BoolVar(&config.ignoreCRAtEOL, "ignore-cr-at-eol", false, "Ignore carriage return at end of line")
 	flag.BoolVar(&config.showStats, "stats", false, "Show diff statistics")
 	flag.BoolVar(&config.showStats, "s", false, "Show diff statistics (short)")
+	flag.BoolVar(&config.numStat, "numstat", false, "Show inserted and deleted line counts per file instead of the diff")
+	flag.BoolVar(&config.shortStat, "shortstat", false, "Show only the total changed files and lines instead of the diff")
+	flag.StringVar(&config.diffAlgorithm, "diff-algorithm", "myers", "Diff algorithm: lcs, myers, patience or histogram")
+	flag.Var(similarityFlag{&config.findRenames}, "find-renames", "Detect renamed files at least `N%` similar (default 50%)")
+	flag.Var(similarityFlag{&config.findRenames}, "M", "Detect renamed files at least `N%` similar (short)")
+	flag.Var(similarityFlag{&config.findCopies}, "find-copies", "Detect copied files at least `N%` similar (default 50%)")
+	flag.BoolVar(&config.sideBySide, "side-by-side", false, "Show old and new lines in two columns")
+	flag.BoolVar(&config.sideBySide, "y", false, "Show old and new lines in two columns (short)")
+	flag.IntVar(&config.width, "width", defaultWidth, "Total width of side-by-side output in `columns`")
+	flag.IntVar(&config.width, "W", defaultWidth, "Total width of side-by-side output (short)")
+	flag.StringVar(&config.intraline, "intraline", "word", "Highlight changed words or characters within changed lines: word, char or none")
+	flag.Var(wordDiffFlag{&config.wordDiff}, "word-diff", "Show changed words inline, as `mode` color, plain (default) or porcelain")
+	flag.StringVar(&config.format, "format", "unified", "Output format: unified, context, normal, ed, rcs, json or html")
+	flag.Var(patternFlag{&config.filter.exclude}, "exclude", "Skip files and directories matching `pattern` (repeatable)")
+	flag.Var(patternFlag{&config.filter.exclude}, "x", "Skip files and directories matching `pattern` (short)")
+	flag.Var(patternFlag{&config.filter.include}, "include", "Only compare files matching `pattern` (repeatable)")
+	flag.Func("exclude-from", "Skip files matching any pattern in `file`", func(name string) error {
+		patterns, err := readPatterns(name)
+		config.filter.exclude = append(config.filter.exclude, patterns...)
+		return err
+	})
+	flag.BoolVar(&config.respectGitignore, "respect-gitignore", false, "Skip files ignored by .gitignore, .git/info/exclude or .ddiffignore")
+	flag.IntVar(&config.jobs, "jobs", runtime.NumCPU(), "Number of files to compare in parallel")
+	flag.IntVar(&config.jobs, "j", runtime.NumCPU(), "Number of files to compare in parallel (short)")
+	for _, mode := range []struct{ name, short, usage string }{
+		{"brief", "q", "Only report whether files differ"},
+		{"name-only", "", "Only list the names of files that differ"},
+		{"name-status", "", "Only list the names and status (A, D, M, R, C or T) of files that differ"},
+	} {
+		mode := mode
+		setMode := func(string) error {
+			config.listMode = mode.name
+			return nil
+		}
+		flag.BoolFunc(mode.name, mode.usage, setMode)
+		if mode.short != "" {
+			flag.BoolFunc(mode.short, mode.usage+" (short)", setMode)
+		}
+	}
+	flag.BoolVar(&config.reportIdentical, "report-identical-files", false, "Report when two files are the same")
+	flag.BoolVar(&config.newFile, "new-file", false, "Treat files present on one side only as empty on the other")
+	flag.BoolVar(&config.newFile, "N", false, "Treat files present on one side only as empty on the other (short)")
+	flag.BoolVar(&config.unidirectionalNewFile, "unidirectional-new-file", false, "Treat files present in the second directory only as empty in the first")
+	var srcPrefixSet, dstPrefixSet, noPrefix bool
+	flag.BoolVar(&config.git, "git", false, "Write git's extended headers, with a/ and b/ path prefixes")
+	flag.Var(prefixFlag{&config.srcPrefix, &srcPrefixSet}, "src-prefix", "Prefix the old file's path in headers with `prefix`")
+	flag.Var(prefixFlag{&config.dstPrefix, &dstPrefixSet}, "dst-prefix", "Prefix the new file's path in headers with `prefix`")
+	flag.BoolVar(&noPrefix, "no-prefix", false, "Write paths in headers without prefixes")
+	flag.BoolFunc("e", "Output an ed script (same as --format=ed)", func(string) error {
+		config.format = "ed"
+		return nil
+	})
+	flag.BoolFunc("n", "Output an RCS format diff (same as --format=rcs)", func(string) error {
+		config.format = "rcs"
+		return nil
+	})
 	
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s [options] <file1|dir1> <file2|dir2>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s merge [options] <base> <ours> <theirs>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s apply [options] [patch|-] [file|dir]\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "\nOptions:\n")
 		flag.PrintDefaults()
 	}
@@ -47,103 +162,330 @@ func main() {
 	
 	if flag.NArg() != 2 {
 		flag.Usage()
-		os.Exit(1)
+		os.Exit(exitTrouble)
+	}
+	
+	if config.git && !srcPrefixSet {
+		config.srcPrefix = defaultSrcPrefix
+	}
+	if config.git && !dstPrefixSet {
+		config.dstPrefix = defaultDstPrefix
+	}
+	if noPrefix {
+		config.srcPrefix, config.dstPrefix = "", ""
+	}
+	
+	if _, ok := diffAlgorithms[config.diffAlgorithm]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown diff algorithm: %s\n", config.diffAlgorithm)
+		os.Exit(exitTrouble)
+	}
+	
+	if !outputFormats[config.format] {
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", config.format)
+		os.Exit(exitTrouble)
+	}
+	
+	if config.intraline != "word" && config.intraline != "char" && config.intraline != "none" {
+		fmt.Fprintf(os.Stderr, "Unknown intraline mode: %s\n", config.intraline)
+		os.Exit(exitTrouble)
+	}
+	
+	if config.jobs < 1 {
+		fmt.Fprintf(os.Stderr, "Jobs must be at least 1\n")
+		os.Exit(exitTrouble)
+	}
+	
+	if config.sideBySide && config.width < 11 {
+		fmt.Fprintf(os.Stderr, "Width must be at least 11 columns\n")
+		os.Exit(exitTrouble)
 	}
 	
 	path1, path2 := flag.Arg(0), flag.Arg(1)
 	
-	info1, err1 := os.Stat(path1)
-	info2, err2 := os.Stat(path2)
+	info1, err1 := statInput(path1)
+	info2, err2 := statInput(path2)
 	
 	if err1 != nil {
 		fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", path1, err1)
-		os.Exit(1)
+		os.Exit(exitTrouble)
 	}
 	
 	if err2 != nil {
 		fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", path2, err2)
-		os.Exit(1)
+		os.Exit(exitTrouble)
 	}
 	
+	// A file is compared with the file of the same name in a directory, as
+	// GNU diff does
+	if info1.IsDir() != info2.IsDir() {
+		if path1 == stdinName || path2 == stdinName {
+			fmt.Fprintf(os.Stderr, "Cannot compare standard input with a directory\n")
+			os.Exit(exitTrouble)
+		}
+		var err error
+		path := ""
+		if info1.IsDir() {
+			path1 = filepath.Join(path1, filepath.Base(path2))
+			path = path1
+			info1, err = os.Stat(path1)
+		} else {
+			path2 = filepath.Join(path2, filepath.Base(path1))
+			path = path2
+			info2, err = os.Stat(path2)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", path, err)
+			os.Exit(exitTrouble)
+		}
+	}
+	
+	if config.format == "html" && showPatch(config) {
+		printHTMLHeader(path1, path2)
+	}
+	
+	var differ bool
 	if info1.IsDir() && info2.IsDir() {
-		err := compareDirs(path1, path2, config)
+		var err error
+		differ, err = compareDirs(path1, path2, config)
+		if config.format == "html" && showPatch(config) {
+			printHTMLFooter()
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error comparing directories: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 	} else if !info1.IsDir() && !info2.IsDir() {
-		err := compareFiles(path1, path2, config)
+		var err error
+		differ, err = compareFiles(path1, path2, config)
+		if config.format == "html" && showPatch(config) {
+			printHTMLFooter()
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error comparing files: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitTrouble)
 		}
 	} else {
 		fmt.Fprintf(os.Stderr, "Cannot compare file with directory\n")
-		os.Exit(1)
+		os.Exit(exitTrouble)
+	}
+	
+	if differ {
+		os.Exit(exitDifferent)
 	}
 }
 
-func compareFiles(file1, file2 string, config Config) error {
-	content1, err := readFileLines(file1)
+// compareFiles prints the diff between two files and reports whether they
+// differ.
+func compareFiles(file1, file2 string, config Config) (bool, error) {
+	name := file1
+	if file1 != file2 {
+		name = file1 + " => " + file2
+	}
+	
+	stat, err := diffFilePair(file1, file2, file1, file2, name, nil, config)
 	if err != nil {
-		return fmt.Errorf("reading %s: %v", file1, err)
+		return false, err
 	}
 	
-	content2, err := readFileLines(file2)
+	printStats([]fileStat{stat}, config)
+	return stat.changed(), nil
+}
+
+// diffFilePair prints the diff between file1 and file2 under the given
+// header labels and returns the change counts for the statistics summary.
+// r is the rename or copy that paired the files, if any.
+func diffFilePair(file1, file2, label1, label2, name string, r *rename, config Config) (fileStat, error) {
+	pair, err := loadFilePair(file1, file2, config)
 	if err != nil {
-		return fmt.Errorf("reading %s: %v", file2, err)
+		return fileStat{path: name, renamed: r != nil}, err
+	}
+	return printFilePair(pair, file1, file2, label1, label2, name, r, config), nil
+}
+
+// filePair is the comparison of two files, ready to be printed.
+type filePair struct {
+	text1, text2 textFile
+	groups       [][]Edit
+	binary       bool // binary files that differ
+	differ       bool
+	mode1, mode2 fs.FileMode // permissions, when they are compared
+}
+
+// loadFilePair reads and diffs two files. Files with the same content are
+// recognized without being read as text or diffed, and when only whether
+// they differ is wanted they are read no further than the first difference.
+func loadFilePair(file1, file2 string, config Config) (filePair, error) {
+	var pair filePair
+	if listing(config) && !ignoresWhitespace(config) && !config.ignoreBlankLines && file1 != stdinName && file2 != stdinName {
+		var err error
+		pair.differ, err = contentDiffers(file1, file2)
+		return pair, err
 	}
 	
-	if isBinary(content1) || isBinary(content2) {
-		if config.showBinary {
-			fmt.Printf("Binary files %s and %s differ\n", file1, file2)
-		}
-		return nil
+	same, err := sameContent(file1, file2)
+	if err != nil || same {
+		return pair, err
 	}
 	
-	diff := generateUnifiedDiff(file1, file2, content1, content2, config)
-	if len(diff) > 0 {
-		printDiff(diff, config)
+	pair.text1, err = readTextFile(file1)
+	if err != nil {
+		return pair, fmt.Errorf("reading %s: %v", file1, err)
+	}
+	
+	pair.text2, err = readTextFile(file2)
+	if err != nil {
+		return pair, fmt.Errorf("reading %s: %v", file2, err)
+	}
+	
+	if isBinary(pair.text1.lines) || isBinary(pair.text2.lines) {
+		pair.binary = true
+		pair.differ = true
+		return pair, nil
 	}
 	
-	return nil
+	pair.groups = diffGroups(pair.text1, pair.text2, config)
+	pair.differ = len(pair.groups) > 0
+	return pair, nil
 }
 
-func compareFilesWithRelativePaths(file1, file2, relPath string, config Config) error {
-	content1, err := readFileLines(file1)
-	if err != nil {
-		return fmt.Errorf("reading %s: %v", file1, err)
+// printFilePair prints a loaded comparison in the configured format and
+// returns its change counts.
+func printFilePair(pair filePair, file1, file2, label1, label2, name string, r *rename, config Config) fileStat {
+	stat := fileStat{path: name, renamed: r != nil}
+	text1, text2, groups := pair.text1, pair.text2, pair.groups
+	
+	var headers []string
+	if gitHeaderFormat(config) {
+		headers = gitHeaders(file1, file2, label1, label2, r, pair.differ, config)
 	}
 	
-	content2, err := readFileLines(file2)
-	if err != nil {
-		return fmt.Errorf("reading %s: %v", file2, err)
+	// Beyond "diff --git", the headers may show a change of mode or a new
+	// or deleted empty file even when the content is the same. Without
+	// them, a change of permissions has a line of its own naming both files.
+	modeChanged := pair.mode1 != pair.mode2 && !gitHeaderFormat(config)
+	if !pair.differ && r == nil && len(headers) <= 1 && !modeChanged {
+		if config.reportIdentical && !structured(config) && (showPatch(config) || config.listMode == "brief") {
+			fmt.Printf("Files %s and %s are identical\n", file1, file2)
+		}
+		return stat
 	}
 	
-	if isBinary(content1) || isBinary(content2) {
-		if config.showBinary {
-			fmt.Printf("Binary files %s differ\n", relPath)
+	if listing(config) {
+		stat.differs = true
+		printListed(file1, file2, label1, label2, r, pair.differ || r != nil, config)
+		return stat
+	}
+	
+	stat.differs = !pair.differ && r == nil
+	if !structured(config) {
+		label1 = withPrefix(config.srcPrefix, label1)
+		label2 = withPrefix(config.dstPrefix, label2)
+	}
+	for _, header := range headers {
+		printColor(config, "white", header+"\n")
+	}
+	if modeChanged && showPatch(config) && !structured(config) {
+		printColor(config, "white", fmt.Sprintf("File %s has mode %s while file %s has mode %s\n", file1, formatMode(pair.mode1), file2, formatMode(pair.mode2)))
+	}
+	
+	if pair.binary {
+		stat.binary = true
+		if structured(config) && showPatch(config) {
+			record := jsonFile{OldPath: label1, NewPath: label2, Status: "binary"}
+			setModes(&record, pair)
+			printRecord(record, config)
+		} else if (config.showBinary || gitHeaderFormat(config)) && showPatch(config) {
+			if label1 == label2 {
+				fmt.Printf("Binary files %s differ\n", label1)
+			} else {
+				fmt.Printf("Binary files %s and %s differ\n", label1, label2)
+			}
 		}
-		return nil
+		return stat
 	}
 	
-	diff := generateUnifiedDiff(relPath, relPath, content1, content2, config)
-	if len(diff) > 0 {
+	stat.insertions, stat.deletions = countChanges(groups)
+	
+	if showPatch(config) && structured(config) {
+		if len(groups) > 0 || r != nil || modeChanged {
+			record := jsonRecord(label1, label2, text1, text2, groups, r, config)
+			setModes(&record, pair)
+			printRecord(record, config)
+		}
+	} else if showPatch(config) && config.format == "context" {
+		printDiff(formatContextDiff(label1, label2, text1, text2, groups, config.showContext), config)
+	} else if showPatch(config) && config.format == "normal" {
+		diff := formatNormalDiff(text1, text2, groups)
+		if len(diff) > 0 && config.inTree {
+			// Without file headers, say which files the commands are for
+			diff = append([]string{fmt.Sprintf("diff %s %s", file1, file2)}, diff...)
+		}
 		printDiff(diff, config)
+	} else if showPatch(config) && (config.format == "ed" || config.format == "rcs") {
+		script := formatRCSDiff(text1, text2, groups)
+		if config.format == "ed" {
+			script = formatEdScript(text1, text2, groups)
+		}
+		if script != "" && config.inTree {
+			fmt.Printf("diff %s %s\n", file1, file2)
+		}
+		fmt.Print(script)
+	} else if showPatch(config) && config.wordDiff != "" {
+		printWordDiff(label1, label2, text1, text2, groups, config)
+	} else if showPatch(config) && config.sideBySide {
+		printSideBySide(label1, label2, text1, text2, groups, config)
+	} else if showPatch(config) {
+		diff := formatUnifiedDiff(label1, label2, text1, text2, groups, config.showContext)
+		if len(diff) > 0 {
+			printDiff(diff, config)
+		}
 	}
 	
-	return nil
+	return stat
 }
 
-func compareDirs(dir1, dir2 string, config Config) error {
-	files1, err := getFileList(dir1, config.recursive)
+// compareDirs prints the differences between two directory trees and
+// reports whether any were found. Files that cannot be compared are
+// reported as they are met, and make the final error non-nil.
+func compareDirs(dir1, dir2 string, config Config) (bool, error) {
+	config.inTree = true
+	
+	filter1, filter2 := config.filter, config.filter
+	if config.respectGitignore {
+		var err error
+		if filter1.ignore, err = newGitignore(dir1); err != nil {
+			return false, fmt.Errorf("reading ignore files in %s: %v", dir1, err)
+		}
+		if filter2.ignore, err = newGitignore(dir2); err != nil {
+			return false, fmt.Errorf("reading ignore files in %s: %v", dir2, err)
+		}
+	}
+	
+	files1, dirs1, err := listTree(dir1, config.recursive, filter1)
 	if err != nil {
-		return fmt.Errorf("listing %s: %v", dir1, err)
+		return false, fmt.Errorf("listing %s: %v", dir1, err)
 	}
 	
-	files2, err := getFileList(dir2, config.recursive)
+	files2, dirs2, err := listTree(dir2, config.recursive, filter2)
 	if err != nil {
-		return fmt.Errorf("listing %s: %v", dir2, err)
+		return false, fmt.Errorf("listing %s: %v", dir2, err)
+	}
+	
+	// A file ignored on either side is left out of both, so that it is not
+	// reported as existing only on the other.
+	if config.respectGitignore {
+		if files1, err = dropIgnored(files1, filter2.ignore, false); err == nil {
+			dirs1, err = dropIgnored(dirs1, filter2.ignore, true)
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading ignore files in %s: %v", dir2, err)
+		}
+		if files2, err = dropIgnored(files2, filter1.ignore, false); err == nil {
+			dirs2, err = dropIgnored(dirs2, filter1.ignore, true)
+		}
+		if err != nil {
+			return false, fmt.Errorf("reading ignore files in %s: %v", dir1, err)
+		}
 	}
 	
 	// Create sets for easier lookup
@@ -171,56 +513,348 @@ func compareDirs(dir1, dir2 string, config Config) error {
 	}
 	sort.Strings(sortedFiles)
 	
+	// Directories are walked along with the files, each coming just before
+	// what it holds
+	dirs1Set := make(map[string]bool)
+	for _, d := range dirs1 {
+		dirs1Set[d] = true
+	}
+	dirs2Set := make(map[string]bool)
+	for _, d := range dirs2 {
+		dirs2Set[d] = true
+	}
+	sortedEntries := append([]string(nil), sortedFiles...)
+	for _, d := range dirs1 {
+		if !allFiles[d] {
+			sortedEntries = append(sortedEntries, d)
+		}
+	}
+	for _, d := range dirs2 {
+		if !allFiles[d] && !dirs1Set[d] {
+			sortedEntries = append(sortedEntries, d)
+		}
+	}
+	sort.Strings(sortedEntries)
+	
+	// Pair files that only exist on one side when they were moved or copied
+	renamedTo := make(map[string]rename)
+	renamedFrom := make(map[string]bool)
+	if config.findRenames > 0 || config.findCopies > 0 {
+		var deleted, added []string
+		for _, f := range sortedFiles {
+			if !files2Set[f] && !dirs2Set[f] {
+				deleted = append(deleted, f)
+			} else if !files1Set[f] && !dirs1Set[f] {
+				added = append(added, f)
+			}
+		}
+		for _, r := range detectRenames(dir1, dir2, deleted, added, files1, config) {
+			renamedTo[r.to] = r
+			if !r.copy {
+				renamedFrom[r.from] = true
+			}
+		}
+	}
+	
+	// Look at what each path present on both sides is, without following
+	// symbolic links, and compare the regular files in the background
+	entries := make(map[string][2]fs.FileInfo)
+	entryErrs := make(map[string]error)
+	var paths1, paths2 []string
 	for _, relPath := range sortedFiles {
+		if !files1Set[relPath] || !files2Set[relPath] {
+			continue
+		}
+		path1 := filepath.Join(dir1, relPath)
+		path2 := filepath.Join(dir2, relPath)
+		info1, err1 := os.Lstat(path1)
+		info2, err2 := os.Lstat(path2)
+		if err1 != nil || err2 != nil {
+			entryErrs[relPath] = errors.Join(err1, err2)
+			continue
+		}
+		entries[relPath] = [2]fs.FileInfo{info1, info2}
+		if info1.Mode().IsRegular() && info2.Mode().IsRegular() {
+			paths1 = append(paths1, path1)
+			paths2 = append(paths2, path2)
+		}
+	}
+	queue := startPairs(paths1, paths2, max(config.jobs, 1), config)
+	next := 0
+	
+	var stats []fileStat
+	differ := false
+	troubles := 0
+	collapsed1 := make(map[string]bool)
+	collapsed2 := make(map[string]bool)
+	holding1 := parentDirs(files1, dirs1)
+	holding2 := parentDirs(files2, dirs2)
+	
+	// A directory that a file was moved out of or into is not collapsed,
+	// so that the move can be shown
+	var movedFrom, movedTo []string
+	for _, r := range renamedTo {
+		movedTo = append(movedTo, r.to)
+		if !r.copy {
+			movedFrom = append(movedFrom, r.from)
+		}
+	}
+	moved1 := parentDirs(movedFrom)
+	moved2 := parentDirs(movedTo)
+	for _, relPath := range sortedEntries {
 		inDir1 := files1Set[relPath]
 		inDir2 := files2Set[relPath]
 		
-		if inDir1 && inDir2 {
+		if dirs1Set[relPath] && dirs2Set[relPath] {
+			continue
+		} else if (dirs1Set[relPath] && inDir2) || (inDir1 && dirs2Set[relPath]) {
+			// A directory on one side and a file on the other
+			info1, err1 := os.Lstat(filepath.Join(dir1, relPath))
+			info2, err2 := os.Lstat(filepath.Join(dir2, relPath))
+			if err := errors.Join(err1, err2); err != nil {
+				fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+				troubles++
+				continue
+			}
+			stat, err := compareSpecial(dir1, dir2, relPath, info1, info2, config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+				troubles++
+				continue
+			}
+			stats = append(stats, stat)
+			differ = true
+			collapsed1[relPath] = dirs1Set[relPath] && !treatAsEmpty(false, config)
+			collapsed2[relPath] = dirs2Set[relPath] && !treatAsEmpty(true, config)
+		} else if inDir1 && inDir2 {
 			// File exists in both directories - compare them
 			path1 := filepath.Join(dir1, relPath)
 			path2 := filepath.Join(dir2, relPath)
 			
-			info1, err1 := os.Stat(path1)
-			info2, err2 := os.Stat(path2)
-			
-			if err1 != nil || err2 != nil {
+			if err := entryErrs[relPath]; err != nil {
+				fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+				troubles++
 				continue
 			}
 			
-			if info1.IsDir() || info2.IsDir() {
+			info := entries[relPath]
+			if !info[0].Mode().IsRegular() || !info[1].Mode().IsRegular() {
+				stat, err := compareSpecial(dir1, dir2, relPath, info[0], info[1], config)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+					troubles++
+					continue
+				}
+				stats = append(stats, stat)
+				differ = differ || stat.changed()
 				continue
 			}
 			
-			err := compareFilesWithRelativePaths(path1, path2, relPath, config)
+			pair, err := queue.wait(next)
+			next++
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+				troubles++
+				continue
 			}
+			pair.mode1, pair.mode2 = permissions(info[0].Mode()), permissions(info[1].Mode())
+			stat := printFilePair(pair, path1, path2, relPath, relPath, relPath, nil, config)
+			stats = append(stats, stat)
+			differ = differ || stat.changed()
+		} else if renamedFrom[relPath] {
+			// Reported along with the file it was renamed to
+			continue
+		} else if r, ok := renamedTo[relPath]; ok {
+			stat, err := compareRenamed(dir1, dir2, r, config)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+				troubles++
+				continue
+			}
+			stats = append(stats, stat)
+			differ = true
+		} else if insideAny(relPath, collapsed1) || insideAny(relPath, collapsed2) {
+			// Reported along with the directory holding it, though it still
+			// counts in the statistics
+			if inDir1 {
+				stats = append(stats, oneSidedStat(filepath.Join(dir1, relPath), relPath, false))
+			} else if inDir2 {
+				stats = append(stats, oneSidedStat(filepath.Join(dir2, relPath), relPath, true))
+			}
+		} else if dirs1Set[relPath] || dirs2Set[relPath] {
+			// Directory only exists on one side - report it as a whole,
+			// unless its files are each to be shown in full
+			added := dirs2Set[relPath]
+			dir, collapsed, holding, moved := dir1, collapsed1, holding1, moved1
+			if added {
+				dir, collapsed, holding, moved = dir2, collapsed2, holding2, moved2
+			}
+			if !treatAsEmpty(added, config) && !moved[relPath] {
+				printOneSidedDir(dir, relPath, added, config)
+				collapsed[relPath] = true
+			} else if !gitHeaderFormat(config) && !holding[relPath] {
+				printOneSidedDir(dir, relPath, added, config)
+			}
+			differ = true
 		} else if inDir1 {
 			// File only exists in dir1 - show as deletion
-			printColor(config, "red", fmt.Sprintf("--- %s\n", relPath))
+			if listing(config) {
+				printListedOneSided(dir1, relPath, false, config)
+			} else if treatAsEmpty(false, config) {
+				if err := diffOneSided(dir1, relPath, false, config); err != nil {
+					fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+					troubles++
+					continue
+				}
+			} else if showPatch(config) && structured(config) {
+				printRecord(jsonFile{OldPath: relPath, Status: "deleted"}, config)
+			} else if showPatch(config) && classicFormat(config) {
+				printOnlyIn(dir1, relPath)
+			} else if showPatch(config) {
+				printColor(config, "red", fmt.Sprintf("--- %s\n", relPath))
+			}
+			stats = append(stats, oneSidedStat(filepath.Join(dir1, relPath), relPath, false))
+			differ = true
 		} else if inDir2 {
 			// File only exists in dir2 - show as addition
-			printColor(config, "green", fmt.Sprintf("+++ %s\n", relPath))
+			if listing(config) {
+				printListedOneSided(dir2, relPath, true, config)
+			} else if treatAsEmpty(true, config) {
+				if err := diffOneSided(dir2, relPath, true, config); err != nil {
+					fmt.Fprintf(os.Stderr, "Error comparing %s: %v\n", relPath, err)
+					troubles++
+					continue
+				}
+			} else if showPatch(config) && structured(config) {
+				printRecord(jsonFile{NewPath: relPath, Status: "added"}, config)
+			} else if showPatch(config) && classicFormat(config) {
+				printOnlyIn(dir2, relPath)
+			} else if showPatch(config) {
+				printColor(config, "green", fmt.Sprintf("+++ %s\n", relPath))
+			}
+			stats = append(stats, oneSidedStat(filepath.Join(dir2, relPath), relPath, true))
+			differ = true
 		}
 	}
 	
-	return nil
+	printStats(stats, config)
+	if troubles > 0 {
+		return differ, fmt.Errorf("%d %s could not be compared", troubles, plural(troubles, "file", "files"))
+	}
+	return differ, nil
+}
+
+// textFile is the content of a file split into lines. eols holds the exact
+// terminator that followed each line: "\n", "\r\n", or "" for a last line
+// with no terminator. A nil eols means every line ends in "\n".
+type textFile struct {
+	lines []string
+	eols  []string
+}
+
+// eol returns the terminator of line i.
+func (text textFile) eol(i int) string {
+	if text.eols == nil {
+		return "\n"
+	}
+	return text.eols[i]
+}
+
+// missingNewline reports whether line i is not terminated by a newline,
+// which for the last line of a file is what "\ No newline at end of file"
+// records.
+func (text textFile) missingNewline(i int) bool {
+	return !strings.HasSuffix(text.eol(i), "\n")
+}
+
+// equal reports whether both files have exactly the same bytes.
+func (text textFile) equal(other textFile) bool {
+	if len(text.lines) != len(other.lines) {
+		return false
+	}
+	for i := range text.lines {
+		if text.lines[i] != other.lines[i] || text.eol(i) != other.eol(i) {
+			return false
+		}
+	}
+	return true
 }
 
 func readFileLines(filename string) ([]string, error) {
+	text, err := readTextFile(filename)
+	return text.lines, err
+}
+
+func readTextFile(filename string) (textFile, error) {
+	if filename == stdinName {
+		return readStdin()
+	}
+	
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return textFile{}, err
 	}
 	defer file.Close()
 	
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	return readText(file)
+}
+
+// readText reads all of r and splits it into lines of any length, keeping
+// each line's terminator so the file can be reproduced byte for byte.
+func readText(r io.Reader) (textFile, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return textFile{}, err
 	}
-	
-	return lines, scanner.Err()
+	return splitLines(string(data)), nil
+}
+
+// splitLines splits data at LF and CRLF terminators. A lone CR does not end
+// a line, as patch, ed and GNU diff count lines, so it stays in the line's
+// text and line numbers agree with theirs. The lines share data's memory
+// rather than being copied.
+func splitLines(data string) textFile {
+	text := textFile{eols: []string{}}
+	for len(data) > 0 {
+		i := strings.IndexByte(data, '\n')
+		if i < 0 {
+			text.lines = append(text.lines, data)
+			text.eols = append(text.eols, "")
+			break
+		}
+		
+		line, eol := data[:i], "\n"
+		if strings.HasSuffix(line, "\r") {
+			line, eol = line[:len(line)-1], "\r\n"
+		}
+		text.lines = append(text.lines, line)
+		text.eols = append(text.eols, eol)
+		data = data[i+1:]
+	}
+	return text
+}
+
+// keys returns the strings diffed in place of the lines of text. Each key
+// carries the line's terminator, so a line-ending change shows up as a
+// change unless the whitespace options in config say to ignore it. A line
+// without a newline never matches one with a newline.
+func (text textFile) keys(config Config) []string {
+	keys := make([]string, len(text.lines))
+	for i, line := range text.lines {
+		eol := text.eol(i)
+		key := line
+		if strings.HasPrefix(eol, "\r") {
+			key += "\r"
+		}
+		if ignoresWhitespace(config) {
+			key = normalizeLine(key, config)
+		}
+		if text.missingNewline(i) {
+			key += "\n"
+		}
+		keys[i] = key
+	}
+	return keys
 }
 
 func isBinary(lines []string) bool {
@@ -234,8 +868,16 @@ func isBinary(lines []string) bool {
 	return false
 }
 
-func getFileList(dir string, recursive bool) ([]string, error) {
-	var files []string
+// getFileList lists the files under dir as paths relative to it, leaving
+// out those the filter skips. Skipped directories are not read at all.
+func getFileList(dir string, recursive bool, filter pathFilter) ([]string, error) {
+	files, _, err := listTree(dir, recursive, filter)
+	return files, err
+}
+
+// listTree is getFileList that also lists the directories under dir, so
+// that one found in only one tree can be reported even when it is empty.
+func listTree(dir string, recursive bool, filter pathFilter) (files, dirs []string, err error) {
 	
 	walkFunc := func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
@@ -258,14 +900,29 @@ func getFileList(dir string, recursive bool) ([]string, error) {
 			return nil
 		}
 		
-		if !info.IsDir() {
+		skip := filter.skip(relPath, info.IsDir())
+		if !skip && filter.ignore != nil {
+			if skip, err = filter.ignore.ignored(relPath, info.IsDir()); err != nil {
+				return err
+			}
+		}
+		if skip {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		
+		if info.IsDir() {
+			dirs = append(dirs, relPath)
+		} else {
 			files = append(files, relPath)
 		}
 		
 		return nil
 	}
 	
-	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -276,21 +933,43 @@ func getFileList(dir string, recursive bool) ([]string, error) {
 		return walkFunc(path, info, err)
 	})
 	
-	return files, err
+	return files, dirs, err
 }
 
 func generateUnifiedDiff(file1, file2 string, lines1, lines2 []string, config Config) []string {
-	var result []string
+	text1, text2 := textFile{lines: lines1}, textFile{lines: lines2}
+	groups := diffGroups(text1, text2, config)
+	return formatUnifiedDiff(file1, file2, text1, text2, groups, config.showContext)
+}
+
+// diffGroups computes the edits between two files and splits them into the
+// groups that become hunks, dropping groups the options in config say to
+// ignore.
+func diffGroups(text1, text2 textFile, config Config) [][]Edit {
+	var groups [][]Edit
 	
-	hunks := generateHunks(lines1, lines2, config.showContext)
+	edits := diffKeys(text1.keys(config), text2.keys(config), config)
+	for _, group := range groupEdits(edits, config.showContext) {
+		if config.ignoreBlankLines && onlyBlankChanges(text1.lines, text2.lines, group, config) {
+			continue
+		}
+		groups = append(groups, group)
+	}
+	
+	return groups
+}
+
+// formatUnifiedDiff renders hunk groups from diffGroups as unified diff lines.
+func formatUnifiedDiff(file1, file2 string, text1, text2 textFile, groups [][]Edit, context int) []string {
+	var result []string
 	
 	// Only add headers if there are actual differences
-	if len(hunks) > 0 {
+	if len(groups) > 0 {
 		result = append(result, fmt.Sprintf("--- %s", file1))
 		result = append(result, fmt.Sprintf("+++ %s", file2))
 		
-		for _, hunk := range hunks {
-			result = append(result, hunk...)
+		for _, group := range groups {
+			result = append(result, formatHunk(text1, text2, group, context)...)
 		}
 	}
 	
@@ -310,10 +989,36 @@ type Edit struct {
 	End2   int
 }
 
+// diffAlgorithms maps the names accepted by --diff-algorithm to their
+// implementations. All of them produce the same Edit slices.
+var diffAlgorithms = map[string]func(lines1, lines2 []string) []Edit{
+	"lcs":       lcsDiff,
+	"myers":     myersDiff,
+	"patience":  patienceDiff,
+	"histogram": histogramDiff,
+}
+
+// computeDiff returns the edit script that turns lines1 into lines2 using
+// the default algorithm.
 func computeDiff(lines1, lines2 []string) []Edit {
+	return myersDiff(lines1, lines2)
+}
+
+// diffKeys runs the algorithm chosen by config over the keys of two files,
+// as returned by textFile.keys. An empty algorithm name selects the default.
+func diffKeys(keys1, keys2 []string, config Config) []Edit {
+	if algorithm, ok := diffAlgorithms[config.diffAlgorithm]; ok {
+		return algorithm(keys1, keys2)
+	}
+	return computeDiff(keys1, keys2)
+}
+
+// lcsDiff is the classic dynamic-programming LCS diff. It needs an
+// (n+1)×(m+1) table, so it is only suitable for small inputs, but its
+// simplicity makes it a useful cross-check for the faster algorithms.
+func lcsDiff(lines1, lines2 []string) []Edit {
 	n, m := len(lines1), len(lines2)
 	
-	// Use simple O(n*m) algorithm for reasonable performance
 	dp := make([][]int, n+1)
 	for i := range dp {
 		dp[i] = make([]int, m+1)
@@ -366,6 +1071,22 @@ func computeDiff(lines1, lines2 []string) []Edit {
 func createHunksFromEdits(lines1, lines2 []string, edits []Edit, context int) [][]string {
 	var hunks [][]string
 	
+	for _, group := range groupEdits(edits, context) {
+		hunk := createSingleHunk(lines1, lines2, group, context)
+		if len(hunk) > 0 {
+			hunks = append(hunks, hunk)
+		}
+	}
+	
+	return hunks
+}
+
+// groupEdits splits edits into the runs that each become one hunk. Each
+// group starts and ends with a change; changes separated by no more than
+// twice the context are kept together so that hunks never overlap.
+func groupEdits(edits []Edit, context int) [][]Edit {
+	var groups [][]Edit
+	
 	i := 0
 	for i < len(edits) {
 		// Skip equal sections until we find changes
@@ -379,46 +1100,60 @@ func createHunksFromEdits(lines1, lines2 []string, edits []Edit, context int) []
 		
 		// Found changes, create a hunk
 		hunkStart := i
+		hunkEnd := i
 		
-		// Include changes until we have enough context
-		for i < len(edits) && (edits[i].Type != "equal" || (edits[i].End1-edits[i].Start1) < context*2) {
+		// Include changes until the gap to the next one is too wide
+		for i < len(edits) {
+			if edits[i].Type != "equal" {
+				i++
+				hunkEnd = i
+				continue
+			}
+			if i+1 >= len(edits) || edits[i].End1-edits[i].Start1 > context*2 {
+				break
+			}
 			i++
 		}
 		
-		hunk := createSingleHunk(lines1, lines2, edits[hunkStart:i], context)
-		if len(hunk) > 0 {
-			hunks = append(hunks, hunk)
-		}
+		groups = append(groups, edits[hunkStart:hunkEnd])
+		i = hunkEnd
 	}
 	
-	return hunks
+	return groups
 }
 
 func createSingleHunk(lines1, lines2 []string, edits []Edit, context int) []string {
+	return formatHunk(textFile{lines: lines1}, textFile{lines: lines2}, edits, context)
+}
+
+// formatHunk renders one group of edits with its surrounding context as a
+// unified diff hunk.
+func formatHunk(text1, text2 textFile, edits []Edit, context int) []string {
 	if len(edits) == 0 {
 		return nil
 	}
+	lines1, lines2 := text1.lines, text2.lines
 	
-	// Calculate hunk boundaries
-	start1 := edits[0].Start1
-	end1 := edits[len(edits)-1].End1
-	start2 := edits[0].Start2
-	end2 := edits[len(edits)-1].End2
-	
-	// Add context
-	contextStart1 := max(0, start1-context)
-	contextEnd1 := min(len(lines1), end1+context)
-	contextStart2 := max(0, start2-context)
-	contextEnd2 := min(len(lines2), end2+context)
+	start1, end1 := edits[0].Start1, edits[len(edits)-1].End1
+	contextStart1, contextEnd1, contextStart2, contextEnd2 := hunkBounds(len(lines1), len(lines2), edits, context)
 	
 	var hunk []string
-	hunk = append(hunk, fmt.Sprintf("@@ -%d,%d +%d,%d @@", 
-		contextStart1+1, contextEnd1-contextStart1,
-		contextStart2+1, contextEnd2-contextStart2))
+	hunk = append(hunk, fmt.Sprintf("@@ -%s +%s @@",
+		hunkRange(contextStart1, contextEnd1), hunkRange(contextStart2, contextEnd2)))
+	
+	// appendLine adds a line, keeping any carriage return in its
+	// terminator, and if it is the unterminated last line of its file, the
+	// marker patch uses to restore that.
+	appendLine := func(prefix string, text textFile, i int) {
+		hunk = append(hunk, prefix+text.lines[i]+strings.TrimSuffix(text.eol(i), "\n"))
+		if text.missingNewline(i) && i == len(text.lines)-1 {
+			hunk = append(hunk, "\\ No newline at end of file")
+		}
+	}
 	
 	// Add context before changes
 	for i := contextStart1; i < start1; i++ {
-		hunk = append(hunk, " "+lines1[i])
+		appendLine(" ", text1, i)
 	}
 	
 	// Add the changes
@@ -426,28 +1161,51 @@ func createSingleHunk(lines1, lines2 []string, edits []Edit, context int) []stri
 		switch edit.Type {
 		case "equal":
 			for i := edit.Start1; i < edit.End1; i++ {
-				hunk = append(hunk, " "+lines1[i])
+				appendLine(" ", text1, i)
 			}
 		case "delete":
 			for i := edit.Start1; i < edit.End1; i++ {
-				hunk = append(hunk, "-"+lines1[i])
+				appendLine("-", text1, i)
 			}
 		case "insert":
 			for i := edit.Start2; i < edit.End2; i++ {
-				hunk = append(hunk, "+"+lines2[i])
+				appendLine("+", text2, i)
 			}
 		}
 	}
 	
 	// Add context after changes
 	for i := end1; i < contextEnd1; i++ {
-		hunk = append(hunk, " "+lines1[i])
+		appendLine(" ", text1, i)
 	}
 	
 	return hunk
 }
 
+// hunkBounds returns the lines [start1, end1) and [start2, end2) that the
+// hunk for a group of edits covers once context is added. The lines around
+// a group are equal on both sides, so the same amount is taken from each.
+func hunkBounds(len1, len2 int, edits []Edit, context int) (start1, end1, start2, end2 int) {
+	start1, end1 = edits[0].Start1, edits[len(edits)-1].End1
+	start2, end2 = edits[0].Start2, edits[len(edits)-1].End2
+	before := min(context, min(start1, start2))
+	after := min(context, min(len1-end1, len2-end2))
+	return start1 - before, end1 + after, start2 - before, end2 + after
+}
 
+// hunkRange formats the lines [start, end) for a hunk header the way GNU
+// diff does: the count is omitted when it is 1, and an empty range names
+// the line before it, so an empty file is "0,0".
+func hunkRange(start, end int) string {
+	switch end - start {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, end-start)
+	}
+}
 
 func max(a, b int) int {
 	if a > b {
@@ -464,7 +1222,25 @@ func min(a, b int) int {
 }
 
 func printDiff(diff []string, config Config) {
-	for _, line := range diff {
+	// On a terminal a carriage return is invisible, which would hide
+	// line-ending changes, so it is shown as ^M there.
+	showCR := config.showColors && supportsColors()
+	
+	var highlighted map[int]string
+	if showCR {
+		diff = append([]string(nil), diff...)
+		for i := range diff {
+			diff[i] = strings.ReplaceAll(diff[i], "\r", "^M")
+		}
+		if config.intraline != "none" {
+			highlighted = highlightHunkLines(diff, config.intraline == "char")
+		}
+	}
+	
+	for i, line := range diff {
+		if h, ok := highlighted[i]; ok {
+			line = h
+		}
 		if len(line) == 0 {
 			fmt.Println()
 			continue
@@ -487,6 +1263,15 @@ func printDiff(diff []string, config Config) {
 				// Added line
 				printColor(config, "green", line+"\n")
 			}
+		case '<':
+			// Deleted line in normal format
+			printColor(config, "red", line+"\n")
+		case '>':
+			// Added line in normal format
+			printColor(config, "green", line+"\n")
+		case '!':
+			// Changed line in context format
+			printColor(config, "yellow", line+"\n")
 		case '@':
 			// Hunk header
 			printColor(config, "cyan", line+"\n")
@@ -505,7 +1290,11 @@ func printColor(config Config, color, text string) {
 		fmt.Print(text)
 		return
 	}
-	
+	printANSI(color, text)
+}
+
+// printANSI prints text in color whether or not the output is a terminal.
+func printANSI(color, text string) {
 	var colorCode string
 	switch color {
 	case "red":
@@ -1,12 +1,27 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 )
 
+// exitCode returns the exit status of a finished command given the error
+// from running it.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
 func TestReadFileLines(t *testing.T) {
 	testFile := "testdata/file1.txt"
 	lines, err := readFileLines(testFile)
@@ -65,7 +80,7 @@ func TestGenerateUnifiedDiff(t *testing.T) {
 }
 
 func TestGetFileList(t *testing.T) {
-	files, err := getFileList("testdata/dir1", false)
+	files, err := getFileList("testdata/dir1", false, pathFilter{})
 	if err != nil {
 		t.Fatalf("Failed to get file list: %v", err)
 	}
@@ -89,7 +104,7 @@ func TestGetFileList(t *testing.T) {
 func TestCompareFiles(t *testing.T) {
 	config := Config{showContext: 3, showColors: false}
 	
-	err := compareFiles("testdata/file1.txt", "testdata/file2.txt", config)
+	_, err := compareFiles("testdata/file1.txt", "testdata/file2.txt", config)
 	if err != nil {
 		t.Fatalf("Failed to compare files: %v", err)
 	}
@@ -98,7 +113,7 @@ func TestCompareFiles(t *testing.T) {
 func TestCLIFileComparison(t *testing.T) {
 	cmd := exec.Command("./ddiff", "--color=false", "testdata/file1.txt", "testdata/file2.txt")
 	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if exitCode(err) != exitDifferent {
 		t.Fatalf("CLI command failed: %v\nOutput: %s", err, output)
 	}
 	
@@ -114,7 +129,7 @@ func TestCLIFileComparison(t *testing.T) {
 func TestCLIDirectoryComparison(t *testing.T) {
 	cmd := exec.Command("./ddiff", "--color=false", "testdata/dir1", "testdata/dir2")
 	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if exitCode(err) != exitDifferent {
 		t.Fatalf("CLI directory comparison failed: %v\nOutput: %s", err, output)
 	}
 	
@@ -150,6 +165,68 @@ func TestCLINonExistentFile(t *testing.T) {
 	}
 }
 
+func TestCLIExitStatus(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	same1 := write("same1.txt", "a\nb\n")
+	same2 := write("same2.txt", "a\nb\n")
+	bin1 := write("bin1", "a\x00b\n")
+	bin2 := write("bin2", "a\x00b\n")
+	bin3 := write("bin3", "a\x00c\n")
+	write("tree1/shared.txt", "x\n")
+	write("tree2/shared.txt", "x\n")
+	write("tree3/shared.txt", "x\n")
+	write("tree3/extra.txt", "y\n")
+	write("tree4/shared.txt", "x\n")
+	write("tree5/shared.txt", "x\n")
+	if err := os.Symlink("missing", filepath.Join(dir, "tree1", "broken.txt")); err != nil {
+		t.Fatal(err)
+	}
+	write("tree4/broken.txt", "z\n")
+	unreadable := write("tree6/shared.txt", "x\n")
+	if err := os.Chmod(unreadable, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"identical files", []string{same1, same2}, exitSame},
+		{"different files", []string{"testdata/file1.txt", "testdata/file2.txt"}, exitDifferent},
+		{"identical binary files", []string{bin1, bin2}, exitSame},
+		{"different binary files", []string{bin1, bin3}, exitDifferent},
+		{"identical directories", []string{filepath.Join(dir, "tree2"), filepath.Join(dir, "tree5")}, exitSame},
+		{"file only in one directory", []string{filepath.Join(dir, "tree2"), filepath.Join(dir, "tree3")}, exitDifferent},
+		{"broken link against a file in directory", []string{filepath.Join(dir, "tree1"), filepath.Join(dir, "tree4")}, exitDifferent},
+		{"unreadable file in directory", []string{filepath.Join(dir, "tree2"), filepath.Join(dir, "tree6")}, exitTrouble},
+		{"missing file", []string{"nonexistent1.txt", "nonexistent2.txt"}, exitTrouble},
+		{"no arguments", nil, exitTrouble},
+		{"file and directory holding it", []string{same1, dir}, exitSame},
+		{"directory and file", []string{dir, "testdata/file1.txt"}, exitTrouble},
+	}
+	for _, c := range cases {
+		if c.name == "unreadable file in directory" && os.Geteuid() == 0 {
+			continue // root can read anything
+		}
+		output, err := exec.Command("./ddiff", append([]string{"--color=false"}, c.args...)...).CombinedOutput()
+		if got := exitCode(err); got != c.want {
+			t.Errorf("%s: expected exit status %d, got %d\nOutput: %s", c.name, c.want, got, output)
+		}
+	}
+}
+
 func TestMaxMin(t *testing.T) {
 	if max(5, 3) != 5 {
 		t.Error("max(5, 3) should return 5")
@@ -191,7 +268,7 @@ func TestLargeFileComparison(t *testing.T) {
 	config := Config{showContext: 3, showColors: false}
 	
 	// Test with larger files to check for performance issues
-	err := compareFiles("testdata/large_file1.txt", "testdata/large_file2.txt", config)
+	_, err := compareFiles("testdata/large_file1.txt", "testdata/large_file2.txt", config)
 	if err != nil {
 		t.Fatalf("Failed to compare large files: %v", err)
 	}
@@ -208,7 +285,7 @@ func TestCLILargeFileComparison(t *testing.T) {
 		output, err = cmd.CombinedOutput()
 	}
 	
-	if err != nil {
+	if exitCode(err) != exitDifferent {
 		t.Logf("Output: %s", output)
 		t.Fatalf("CLI large file comparison failed or timed out: %v", err)
 	}
@@ -232,7 +309,7 @@ func BenchmarkLargeFileComparison(b *testing.B) {
 	
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		err := compareFiles("testdata/large_file1.txt", "testdata/large_file2.txt", config)
+		_, err := compareFiles("testdata/large_file1.txt", "testdata/large_file2.txt", config)
 		if err != nil {
 			b.Fatalf("Failed to compare large files: %v", err)
 		}
@@ -242,7 +319,7 @@ func BenchmarkLargeFileComparison(b *testing.B) {
 func TestRecursiveDirectoryComparison(t *testing.T) {
 	config := Config{showContext: 3, showColors: false, recursive: true}
 	
-	err := compareDirs("testdata/deep1", "testdata/deep2", config)
+	_, err := compareDirs("testdata/deep1", "testdata/deep2", config)
 	if err != nil {
 		t.Fatalf("Failed to compare recursive directories: %v", err)
 	}
@@ -252,7 +329,7 @@ func TestCLIRecursiveDirectoryComparison(t *testing.T) {
 	// Test that recursive is now the default behavior
 	cmd := exec.Command("./ddiff", "--color=false", "testdata/deep1", "testdata/deep2")
 	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if exitCode(err) != exitDifferent {
 		t.Fatalf("CLI recursive directory comparison failed: %v\nOutput: %s", err, output)
 	}
 	
@@ -260,8 +337,8 @@ func TestCLIRecursiveDirectoryComparison(t *testing.T) {
 	if !strings.Contains(outputStr, "src/models/user.go") {
 		t.Error("CLI output should show differences in nested files by default")
 	}
-	if !strings.Contains(outputStr, "src/utils/helpers.go") {
-		t.Error("CLI output should show files only in deep2")
+	if !strings.Contains(outputStr, "Only in "+filepath.Join("testdata", "deep2", "src")+": utils/") {
+		t.Error("CLI output should show directories only in deep2")
 	}
 }
 
@@ -269,7 +346,7 @@ func TestNonRecursiveVsRecursive(t *testing.T) {
 	// Test that --recursive=false disables recursive behavior
 	cmd := exec.Command("./ddiff", "--color=false", "--recursive=false", "testdata/deep1", "testdata/deep2")
 	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if exitCode(err) != exitDifferent {
 		t.Fatalf("CLI non-recursive comparison failed: %v\nOutput: %s", err, output)
 	}
 	
@@ -278,13 +355,16 @@ func TestNonRecursiveVsRecursive(t *testing.T) {
 	if strings.Contains(outputStr, "src/models/user.go") {
 		t.Error("Non-recursive mode should not show nested file differences")
 	}
+	if !strings.Contains(outputStr, "Only in "+filepath.Join("testdata", "deep2")+": scripts/") {
+		t.Error("Non-recursive mode should still show top-level directories only in deep2")
+	}
 }
 
 func TestShortFlags(t *testing.T) {
 	// Test short flag equivalents (recursive is now default, so just test colors)
 	cmd := exec.Command("./ddiff", "-c=false", "testdata/deep1", "testdata/deep2")
 	output, err := cmd.CombinedOutput()
-	if err != nil {
+	if exitCode(err) != exitDifferent {
 		t.Fatalf("CLI short flags failed: %v\nOutput: %s", err, output)
 	}
 	
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// change is a region [base1, base2) of the base file that one side
+// replaced with its lines [side1, side2).
+type change struct {
+	base1, base2 int
+	side1, side2 int
+}
+
+// changesFromEdits collapses an edit script into the regions of the base
+// that differ, merging each delete with the insert that follows it.
+func changesFromEdits(edits []Edit) []change {
+	var changes []change
+	for i := 0; i < len(edits); i++ {
+		if edits[i].Type == "equal" {
+			continue
+		}
+		c := change{edits[i].Start1, edits[i].End1, edits[i].Start2, edits[i].End2}
+		for i+1 < len(edits) && edits[i+1].Type != "equal" {
+			i++
+			c.base2 = edits[i].End1
+			c.side2 = edits[i].End2
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// sideRange maps the base region [lo, hi) onto one side of the merge,
+// given all of that side's changes and those that fall inside the region
+// (possibly none).
+func sideRange(changes []change, lo, hi int, inside []change) (int, int) {
+	if len(inside) > 0 {
+		first, last := inside[0], inside[len(inside)-1]
+		return first.side1 - (first.base1 - lo), last.side2 + (hi - last.base2)
+	}
+
+	// Unchanged on this side: shift by the changes that come before.
+	offset := 0
+	for _, c := range changes {
+		if c.base2 > lo {
+			break
+		}
+		offset = c.side2 - c.base2
+	}
+	return lo + offset, hi + offset
+}
+
+// mergeRegion is a run of the base where at least one side changed.
+type mergeRegion struct {
+	lo, hi       int
+	ours, theirs []change
+}
+
+// mergeRegions groups the changes of both sides into regions, treating
+// changes that overlap or touch in the base as one region.
+func mergeRegions(ours, theirs []change) []mergeRegion {
+	type tagged struct {
+		change
+		theirs bool
+	}
+	var all []tagged
+	for _, c := range ours {
+		all = append(all, tagged{c, false})
+	}
+	for _, c := range theirs {
+		all = append(all, tagged{c, true})
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].base1 < all[j].base1
+	})
+
+	var regions []mergeRegion
+	for _, t := range all {
+		if n := len(regions); n == 0 || t.base1 > regions[n-1].hi {
+			regions = append(regions, mergeRegion{lo: t.base1, hi: t.base2})
+		}
+		r := &regions[len(regions)-1]
+		r.hi = max(r.hi, t.base2)
+		if t.theirs {
+			r.theirs = append(r.theirs, t.change)
+		} else {
+			r.ours = append(r.ours, t.change)
+		}
+	}
+	return regions
+}
+
+// mergeLabels names the three inputs in conflict markers.
+type mergeLabels struct {
+	base, ours, theirs string
+}
+
+// mergeTexts performs a three-way merge of ours and theirs against base.
+// Non-overlapping changes are applied automatically; overlapping ones that
+// differ are written between conflict markers, with the base version too
+// when style is "diff3". It returns the merged content and the number of
+// conflicts.
+func mergeTexts(base, ours, theirs textFile, labels mergeLabels, style string, config Config) (string, int) {
+	baseKeys := base.keys(config)
+	oursChanges := changesFromEdits(diffKeys(baseKeys, ours.keys(config), config))
+	theirsChanges := changesFromEdits(diffKeys(baseKeys, theirs.keys(config), config))
+
+	var out strings.Builder
+	conflicts := 0
+	pos := 0
+
+	for _, r := range mergeRegions(oursChanges, theirsChanges) {
+		writeLines(&out, base, pos, r.lo)
+		pos = r.hi
+
+		ours1, ours2 := sideRange(oursChanges, r.lo, r.hi, r.ours)
+		theirs1, theirs2 := sideRange(theirsChanges, r.lo, r.hi, r.theirs)
+
+		switch {
+		case len(r.theirs) == 0:
+			writeLines(&out, ours, ours1, ours2)
+		case len(r.ours) == 0:
+			writeLines(&out, theirs, theirs1, theirs2)
+		case sameLines(ours, ours1, ours2, theirs, theirs1, theirs2):
+			// Both sides made the same change.
+			writeLines(&out, ours, ours1, ours2)
+		default:
+			conflicts++
+			out.WriteString("<<<<<<< " + labels.ours + "\n")
+			writeConflictLines(&out, ours, ours1, ours2)
+			if style == "diff3" {
+				out.WriteString("||||||| " + labels.base + "\n")
+				writeConflictLines(&out, base, r.lo, r.hi)
+			}
+			out.WriteString("=======\n")
+			writeConflictLines(&out, theirs, theirs1, theirs2)
+			out.WriteString(">>>>>>> " + labels.theirs + "\n")
+		}
+	}
+	writeLines(&out, base, pos, len(base.lines))
+
+	return out.String(), conflicts
+}
+
+// writeLines copies lines [from, to) of text with their exact terminators.
+func writeLines(out *strings.Builder, text textFile, from, to int) {
+	for i := from; i < to; i++ {
+		out.WriteString(text.lines[i])
+		out.WriteString(text.eol(i))
+	}
+}
+
+// writeConflictLines is writeLines for a conflict section, where the
+// following marker must start on a line of its own.
+func writeConflictLines(out *strings.Builder, text textFile, from, to int) {
+	writeLines(out, text, from, to)
+	if to > from && text.missingNewline(to-1) {
+		out.WriteString("\n")
+	}
+}
+
+func sameLines(a textFile, a1, a2 int, b textFile, b1, b2 int) bool {
+	if a2-a1 != b2-b1 {
+		return false
+	}
+	for k := 0; k < a2-a1; k++ {
+		if a.lines[a1+k] != b.lines[b1+k] || a.eol(a1+k) != b.eol(b1+k) {
+			return false
+		}
+	}
+	return true
+}
+
+// runMerge implements "ddiff merge [options] base ours theirs" and returns
+// the exit status: 0 for a clean merge, 1 if conflicts remain, 2 on
+// trouble.
+func runMerge(args []string) int {
+	flags := flag.NewFlagSet("merge", flag.ContinueOnError)
+	config := Config{}
+	var style, output string
+	flags.StringVar(&style, "style", "merge", "Conflict style: merge or diff3")
+	flags.StringVar(&output, "output", "", "Write the merged result to `file` instead of standard output")
+	flags.StringVar(&output, "o", "", "Write the merged result to `file` (short)")
+	flags.StringVar(&config.diffAlgorithm, "diff-algorithm", "myers", "Diff algorithm: lcs, myers, patience or histogram")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s merge [options] <base> <ours> <theirs>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitTrouble
+	}
+	if flags.NArg() != 3 {
+		flags.Usage()
+		return exitTrouble
+	}
+	if style != "merge" && style != "diff3" {
+		fmt.Fprintf(os.Stderr, "Unknown conflict style: %s\n", style)
+		return exitTrouble
+	}
+	if _, ok := diffAlgorithms[config.diffAlgorithm]; !ok {
+		fmt.Fprintf(os.Stderr, "Unknown diff algorithm: %s\n", config.diffAlgorithm)
+		return exitTrouble
+	}
+
+	var texts [3]textFile
+	for i, path := range flags.Args() {
+		text, err := readTextFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return exitTrouble
+		}
+		if isBinary(text.lines) {
+			fmt.Fprintf(os.Stderr, "Cannot merge binary file %s\n", path)
+			return exitTrouble
+		}
+		texts[i] = text
+	}
+
+	labels := mergeLabels{base: flags.Arg(0), ours: flags.Arg(1), theirs: flags.Arg(2)}
+	merged, conflicts := mergeTexts(texts[0], texts[1], texts[2], labels, style, config)
+
+	if output == "" {
+		fmt.Print(merged)
+	} else if err := os.WriteFile(output, []byte(merged), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", output, err)
+		return exitTrouble
+	}
+
+	if conflicts > 0 {
+		fmt.Fprintf(os.Stderr, "%d %s\n", conflicts, plural(conflicts, "conflict", "conflicts"))
+		return exitDifferent
+	}
+	return exitSame
+}
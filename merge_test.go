@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeTexts(t *testing.T) {
+	labels := mergeLabels{base: "base", ours: "ours", theirs: "theirs"}
+	cases := []struct {
+		name               string
+		base, ours, theirs string
+		style              string
+		want               string
+		conflicts          int
+	}{
+		{
+			name:   "disjoint changes",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "A\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "A\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "insertions on both sides",
+			base:   "a\nb\nc\n",
+			ours:   "x\na\nb\nc\n",
+			theirs: "a\nb\nc\ny\n",
+			want:   "x\na\nb\nc\ny\n",
+		},
+		{
+			name:   "same change on both sides",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nB\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "only theirs changed",
+			base:   "a\nb\n",
+			ours:   "a\nb\n",
+			theirs: "a\n",
+			want:   "a\n",
+		},
+		{
+			name:      "conflict",
+			base:      "a\nb\nc\n",
+			ours:      "a\nX\nc\n",
+			theirs:    "a\nY\nc\n",
+			style:     "merge",
+			want:      "a\n<<<<<<< ours\nX\n=======\nY\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "diff3 conflict",
+			base:      "a\nb\nc\n",
+			ours:      "a\nX\nc\n",
+			theirs:    "a\nY\nc\n",
+			style:     "diff3",
+			want:      "a\n<<<<<<< ours\nX\n||||||| base\nb\n=======\nY\n>>>>>>> theirs\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "conflicting appends without final newline",
+			base:      "a\n",
+			ours:      "a\nx",
+			theirs:    "a\ny",
+			want:      "a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\n",
+			conflicts: 1,
+		},
+		{
+			name:      "conflict and clean change",
+			base:      "1\n2\n3\n4\n5\n6\n",
+			ours:      "one\n2\n3\n4\nfive\n6\n",
+			theirs:    "1\n2\n3\n4\nFIVE\n6\nseven\n",
+			want:      "one\n2\n3\n4\n<<<<<<< ours\nfive\n=======\nFIVE\n>>>>>>> theirs\n6\nseven\n",
+			conflicts: 1,
+		},
+	}
+
+	for _, c := range cases {
+		got, conflicts := mergeTexts(splitLines(c.base), splitLines(c.ours), splitLines(c.theirs), labels, c.style, Config{})
+		if got != c.want {
+			t.Errorf("%s: expected\n%s\ngot\n%s", c.name, c.want, got)
+		}
+		if conflicts != c.conflicts {
+			t.Errorf("%s: expected %d conflicts, got %d", c.name, c.conflicts, conflicts)
+		}
+	}
+}
+
+func TestCLIMerge(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base.txt", "a\nb\nc\n")
+	ours := write("ours.txt", "A\nb\nc\n")
+	theirs := write("theirs.txt", "a\nb\nC\n")
+	conflicting := write("conflicting.txt", "a2\nb\nc\n")
+
+	output, err := exec.Command("./ddiff", "merge", base, ours, theirs).Output()
+	if code := exitCode(err); code != exitSame {
+		t.Fatalf("clean merge: expected exit status 0, got %d", code)
+	}
+	if string(output) != "A\nb\nC\n" {
+		t.Errorf("clean merge: unexpected output %q", output)
+	}
+
+	result := filepath.Join(dir, "result.txt")
+	output, err = exec.Command("./ddiff", "merge", "--style=diff3", "-o", result, base, ours, conflicting).Output()
+	if code := exitCode(err); code != exitDifferent {
+		t.Fatalf("conflicting merge: expected exit status 1, got %d", code)
+	}
+	if len(output) != 0 {
+		t.Errorf("with -o nothing should be written to standard output, got %q", output)
+	}
+	merged, err := os.ReadFile(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(merged), "||||||| "+base+"\na\n=======\n") {
+		t.Errorf("expected diff3 conflict markers, got\n%s", merged)
+	}
+
+	_, err = exec.Command("./ddiff", "merge", base, ours).Output()
+	if code := exitCode(err); code != exitTrouble {
+		t.Errorf("missing argument: expected exit status 2, got %d", code)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+// myersDiff computes an edit script between lines1 and lines2 using Myers'
+// O(ND) algorithm with the linear-space divide-and-conquer refinement. The
+// script is minimal unless the search hits the cost cap described below. Memory use is O(n+m) regardless of how different the inputs
+// are, so very large files can be compared without building a table.
+//
+// As in GNU diff, lines that do not appear at all on the other side are
+// marked changed before the search, since they can never be matched, and a
+// search that grows too expensive settles for a good edit script rather
+// than a minimal one.
+func myersDiff(lines1, lines2 []string) []Edit {
+	a, b := internLines(lines1, lines2)
+	changed1, changed2 := make([]bool, len(a)), make([]bool, len(b))
+	keptA, indexA := discardUnmatched(a, b, changed1)
+	keptB, indexB := discardUnmatched(b, a, changed2)
+
+	d := newDiffer(keptA, keptB)
+	d.compare(0, len(keptA), 0, len(keptB))
+	for i, changed := range d.changed1 {
+		changed1[indexA[i]] = changed
+	}
+	for j, changed := range d.changed2 {
+		changed2[indexB[j]] = changed
+	}
+	return editsFromChanges(changed1, changed2)
+}
+
+// discardUnmatched marks in changed the lines of a that never occur in b,
+// and returns the other lines of a along with their indexes in a. Taking
+// the unmatched lines out leaves the longest common subsequence the same,
+// but can greatly shorten the search when most changes are new lines.
+func discardUnmatched(a, b []int, changed []bool) ([]int, []int) {
+	inB := make(map[int]bool, len(b))
+	for _, id := range b {
+		inB[id] = true
+	}
+	var kept, index []int
+	for i, id := range a {
+		if inB[id] {
+			kept = append(kept, id)
+			index = append(index, i)
+		} else {
+			changed[i] = true
+		}
+	}
+	return kept, index
+}
+
+// internLines maps each distinct line to a small integer so that the diff
+// algorithms compare ints rather than strings.
+func internLines(lines1, lines2 []string) ([]int, []int) {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		result := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			result[i] = id
+		}
+		return result
+	}
+	return intern(lines1), intern(lines2)
+}
+
+// editsFromChanges turns per-line change marks into the run-length Edit
+// slices used by the hunk builder. Lines not marked on either side must pair
+// up one-to-one; deletions are emitted before insertions at each change.
+func editsFromChanges(changed1, changed2 []bool) []Edit {
+	var edits []Edit
+	n, m := len(changed1), len(changed2)
+	i, j := 0, 0
+
+	for i < n || j < m {
+		switch {
+		case i < n && changed1[i]:
+			start := i
+			for i < n && changed1[i] {
+				i++
+			}
+			edits = append(edits, Edit{Type: "delete", Start1: start, End1: i, Start2: j, End2: j})
+		case j < m && changed2[j]:
+			start := j
+			for j < m && changed2[j] {
+				j++
+			}
+			edits = append(edits, Edit{Type: "insert", Start1: i, End1: i, Start2: start, End2: j})
+		default:
+			start1, start2 := i, j
+			for i < n && j < m && !changed1[i] && !changed2[j] {
+				i++
+				j++
+			}
+			if i == start1 {
+				// Unpaired unchanged lines mean the marks are inconsistent;
+				// treat whatever is left as changed rather than loop forever.
+				edits = append(edits, Edit{Type: "delete", Start1: i, End1: n, Start2: j, End2: j})
+				edits = append(edits, Edit{Type: "insert", Start1: n, End1: n, Start2: j, End2: m})
+				i, j = n, m
+				break
+			}
+			edits = append(edits, Edit{Type: "equal", Start1: start1, End1: i, Start2: start2, End2: j})
+		}
+	}
+
+	return edits
+}
+
+// differ holds the state for one comparison of interned lines. Lines found
+// to differ are recorded in changed1 and changed2; each algorithm works on
+// index ranges of a and b so they can hand sub-ranges to one another.
+type differ struct {
+	a, b               []int
+	changed1, changed2 []bool
+	v                  []int
+	tooExpensive       int // steps after which middleSnake gives up on the best split
+}
+
+func newDiffer(a, b []int) *differ {
+	// Like GNU diff, allow roughly the square root of the number of
+	// diagonals, but never fewer than 4096 steps
+	tooExpensive := 1
+	for diags := len(a) + len(b) + 3; diags != 0; diags >>= 2 {
+		tooExpensive <<= 1
+	}
+	return &differ{
+		a:            a,
+		b:            b,
+		changed1:     make([]bool, len(a)),
+		changed2:     make([]bool, len(b)),
+		tooExpensive: max(tooExpensive, 4096),
+	}
+}
+
+// trim strips the common prefix and suffix of a[aLo:aHi] and b[bLo:bHi]. If
+// either side is then empty, the remainder of the other is marked changed
+// and done is true.
+func (d *differ) trim(aLo, aHi, bLo, bHi int) (int, int, int, int, bool) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	if aLo == aHi || bLo == bHi {
+		d.markChanged(aLo, aHi, bLo, bHi)
+		return aLo, aHi, bLo, bHi, true
+	}
+	return aLo, aHi, bLo, bHi, false
+}
+
+// markChanged records every line in both ranges as changed.
+func (d *differ) markChanged(aLo, aHi, bLo, bHi int) {
+	for i := aLo; i < aHi; i++ {
+		d.changed1[i] = true
+	}
+	for j := bLo; j < bHi; j++ {
+		d.changed2[j] = true
+	}
+}
+
+// compare diffs a[aLo:aHi] against b[bLo:bHi], recursing on the middle snake.
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi, done := d.trim(aLo, aHi, bLo, bHi)
+	if done {
+		return
+	}
+
+	x, y, ok := d.middleSnake(aLo, aHi, bLo, bHi)
+	if !ok || (x == 0 && y == 0) || (x == aHi-aLo && y == bHi-bLo) {
+		d.markChanged(aLo, aHi, bLo, bHi)
+		return
+	}
+	d.compare(aLo, aLo+x, bLo, bLo+y)
+	d.compare(aLo+x, aHi, bLo+y, bHi)
+}
+
+// middleSnake runs the forward and reverse searches simultaneously until they
+// overlap and returns a point, relative to (aLo, bLo), that lies on an
+// optimal edit path. The caller has already stripped any common prefix and
+// suffix, so the returned point always splits the problem into two strictly
+// smaller ones. After tooExpensive steps it returns the point furthest from
+// either corner that a search has reached instead, which still splits the
+// problem but may not lie on an optimal path.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (int, int, bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+	length := 2*maxD + 2
+
+	if cap(d.v) < 2*length {
+		d.v = make([]int, 2*length)
+	}
+	v1 := d.v[:length]
+	v2 := d.v[length : 2*length]
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[offset+1] = 0
+	v2[offset+1] = 0
+
+	delta := n - m
+	front := delta%2 != 0
+	k1start, k1end, k2start, k2end := 0, 0, 0, 0
+
+	for step := 0; step < maxD; step++ {
+		if step >= d.tooExpensive {
+			return furthestPoint(v1, v2, offset, step, n, m)
+		}
+
+		// Walk the forward path one step.
+		for k1 := -step + k1start; k1 <= step-k1end; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -step || (k1 != step && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.a[aLo+x1] == d.b[bLo+y1] {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			if x1 > n {
+				k1end += 2
+			} else if y1 > m {
+				k1start += 2
+			} else if front {
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < length && v2[k2Offset] != -1 {
+					if x1 >= n-v2[k2Offset] {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+
+		// Walk the reverse path one step.
+		for k2 := -step + k2start; k2 <= step-k2end; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -step || (k2 != step && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.a[aHi-x2-1] == d.b[bHi-y2-1] {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			if x2 > n {
+				k2end += 2
+			} else if y2 > m {
+				k2start += 2
+			} else if !front {
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < length && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// furthestPoint returns the point reached by the forward or reverse search
+// of middleSnake, relative to the start of the range, that has got furthest
+// from its corner in the given number of steps. Every diagonal a search has
+// been on holds a point it reached; the others are still -1.
+func furthestPoint(v1, v2 []int, offset, step, n, m int) (int, int, bool) {
+	bestX, bestY, best := 0, 0, -1
+	for k := -step; k <= step; k++ {
+		if x1 := v1[offset+k]; x1 >= 0 && x1 <= n && x1-k >= 0 && x1-k <= m && 2*x1-k > best {
+			bestX, bestY, best = x1, x1-k, 2*x1-k
+		}
+		if x2 := v2[offset+k]; x2 >= 0 && x2 <= n && x2-k >= 0 && x2-k <= m && 2*x2-k > best {
+			bestX, bestY, best = n-x2, m-(x2-k), 2*x2-k
+		}
+	}
+	return bestX, bestY, best > 0
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// checkEdits verifies that edits are contiguous, cover both inputs and
+// transform lines1 into lines2. It returns the number of equal lines.
+func checkEdits(t *testing.T, lines1, lines2 []string, edits []Edit) int {
+	t.Helper()
+	i, j, common := 0, 0, 0
+	var rebuilt []string
+	for _, e := range edits {
+		if e.Start1 != i || e.Start2 != j {
+			t.Fatalf("edit %+v does not start at (%d,%d)", e, i, j)
+		}
+		switch e.Type {
+		case "equal":
+			if e.End1-e.Start1 != e.End2-e.Start2 {
+				t.Fatalf("unbalanced equal edit %+v", e)
+			}
+			for k := 0; k < e.End1-e.Start1; k++ {
+				if lines1[e.Start1+k] != lines2[e.Start2+k] {
+					t.Fatalf("equal edit %+v pairs different lines", e)
+				}
+			}
+			rebuilt = append(rebuilt, lines2[e.Start2:e.End2]...)
+			common += e.End1 - e.Start1
+		case "delete":
+			if e.Start2 != e.End2 {
+				t.Fatalf("delete edit %+v consumes new lines", e)
+			}
+		case "insert":
+			if e.Start1 != e.End1 {
+				t.Fatalf("insert edit %+v consumes old lines", e)
+			}
+			rebuilt = append(rebuilt, lines2[e.Start2:e.End2]...)
+		default:
+			t.Fatalf("unknown edit type %q", e.Type)
+		}
+		i, j = e.End1, e.End2
+	}
+	if i != len(lines1) || j != len(lines2) {
+		t.Fatalf("edits end at (%d,%d), want (%d,%d)", i, j, len(lines1), len(lines2))
+	}
+	if len(rebuilt) != len(lines2) {
+		t.Fatalf("rebuilt %d lines, want %d", len(rebuilt), len(lines2))
+	}
+	return common
+}
+
+// lcsLength returns the length of the longest common subsequence.
+func lcsLength(lines1, lines2 []string) int {
+	prev := make([]int, len(lines2)+1)
+	cur := make([]int, len(lines2)+1)
+	for i := 1; i <= len(lines1); i++ {
+		for j := 1; j <= len(lines2); j++ {
+			if lines1[i-1] == lines2[j-1] {
+				cur[j] = prev[j-1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j-1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(lines2)]
+}
+
+func randomLines(r *rand.Rand, n, alphabet int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", r.Intn(alphabet))
+	}
+	return lines
+}
+
+func TestMyersDiffMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for iter := 0; iter < 500; iter++ {
+		lines1 := randomLines(r, r.Intn(40), 1+r.Intn(6))
+		lines2 := randomLines(r, r.Intn(40), 1+r.Intn(6))
+
+		checkEdits(t, lines1, lines2, lcsDiff(lines1, lines2))
+		want := lcsLength(lines1, lines2)
+		got := checkEdits(t, lines1, lines2, myersDiff(lines1, lines2))
+		if got != want {
+			t.Fatalf("myers kept %d common lines, LCS has %d\nlines1=%q\nlines2=%q", got, want, lines1, lines2)
+		}
+	}
+}
+
+func TestMyersDiffEdgeCases(t *testing.T) {
+	cases := []struct {
+		lines1, lines2 []string
+	}{
+		{nil, nil},
+		{nil, []string{"a"}},
+		{[]string{"a"}, nil},
+		{[]string{"a"}, []string{"b"}},
+		{[]string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{[]string{"a", "b", "c"}, []string{"c", "b", "a"}},
+	}
+	for _, c := range cases {
+		checkEdits(t, c.lines1, c.lines2, myersDiff(c.lines1, c.lines2))
+	}
+
+	if edits := myersDiff(nil, nil); len(edits) != 0 {
+		t.Errorf("expected no edits for empty inputs, got %+v", edits)
+	}
+}
+
+func TestMyersDiffLargeInput(t *testing.T) {
+	// 200k lines each would need a 40-billion-cell table with the LCS
+	// algorithm; the linear-space search handles it directly.
+	const n = 200000
+	lines1 := make([]string, n)
+	lines2 := make([]string, n)
+	for i := 0; i < n; i++ {
+		lines1[i] = fmt.Sprintf("log entry %d", i)
+		lines2[i] = lines1[i]
+		if i%1000 == 0 {
+			lines2[i] = fmt.Sprintf("changed entry %d", i)
+		}
+	}
+
+	common := checkEdits(t, lines1, lines2, myersDiff(lines1, lines2))
+	if want := n - n/1000; common != want {
+		t.Errorf("expected %d common lines, got %d", want, common)
+	}
+}
+
+func TestMyersDiffManyChanges(t *testing.T) {
+	// Every changed line is new, so all of them are discarded before the
+	// search and the rest match up directly.
+	const n = 200000
+	lines1 := make([]string, n)
+	lines2 := make([]string, n)
+	for i := 0; i < n; i++ {
+		lines1[i] = fmt.Sprintf("log entry %d", i)
+		lines2[i] = lines1[i]
+		if i%10 == 0 {
+			lines2[i] = fmt.Sprintf("changed entry %d", i)
+		}
+	}
+	if common := checkEdits(t, lines1, lines2, myersDiff(lines1, lines2)); common != n-n/10 {
+		t.Errorf("expected %d common lines, got %d", n-n/10, common)
+	}
+
+	for i := range lines2 {
+		lines2[i] = fmt.Sprintf("other entry %d", i)
+	}
+	if common := checkEdits(t, lines1, lines2, myersDiff(lines1, lines2)); common != 0 {
+		t.Errorf("expected no common lines, got %d", common)
+	}
+}
+
+func TestMyersDiffTooExpensive(t *testing.T) {
+	// With the search cut short almost at once, the split points are only
+	// guesses, but the edits must still turn one input into the other.
+	r := rand.New(rand.NewSource(1))
+	for iter := 0; iter < 200; iter++ {
+		lines1 := randomLines(r, r.Intn(60), 1+r.Intn(6))
+		lines2 := randomLines(r, r.Intn(60), 1+r.Intn(6))
+		a, b := internLines(lines1, lines2)
+		d := newDiffer(a, b)
+		d.tooExpensive = 1 + r.Intn(3)
+		d.compare(0, len(a), 0, len(b))
+		checkEdits(t, lines1, lines2, editsFromChanges(d.changed1, d.changed2))
+	}
+}
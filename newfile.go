@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// treatAsEmpty reports whether a file found only in one tree should be
+// diffed against an empty file rather than just named: with --new-file for
+// files on either side or with --git, as git always does, and with
+// --unidirectional-new-file for files that are only in the second tree.
+// Listing modes and structured output have their own way of reporting such
+// files.
+func treatAsEmpty(added bool, config Config) bool {
+	if !showPatch(config) || structured(config) {
+		return false
+	}
+	return config.newFile || gitHeaderFormat(config) || (added && config.unidirectionalNewFile)
+}
+
+// diffOneSided prints a file found only in dir as a diff against an empty
+// file, named /dev/null in the headers as git does, so that the patch
+// creates or deletes it. A symbolic link is shown by its target, and other
+// special files, which have no content to show, are just named.
+func diffOneSided(dir, relPath string, added bool, config Config) error {
+	path := filepath.Join(dir, relPath)
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if added {
+			printFilePair(linkPair("", target, config), os.DevNull, path, devNull, relPath, relPath, nil, config)
+		} else {
+			printFilePair(linkPair(target, "", config), path, os.DevNull, relPath, devNull, relPath, nil, config)
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		printOnlyIn(dir, relPath)
+		return nil
+	}
+
+	if added {
+		_, err = diffFilePair(os.DevNull, path, devNull, relPath, relPath, nil, config)
+	} else {
+		_, err = diffFilePair(path, os.DevNull, relPath, devNull, relPath, nil, config)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCLINewFileMakesACompletePatch(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old")
+	writeTree(t, old, map[string]string{
+		"kept.txt":     "1\n2\n3\n",
+		"gone.txt":     "bye\nfor now\n",
+		"sub/gone.txt": "deep\n",
+	})
+	new := filepath.Join(dir, "new")
+	writeTree(t, new, map[string]string{
+		"kept.txt":      "1\ntwo\n3\n",
+		"added.txt":     "hello\n",
+		"sub/added.txt": "no newline",
+	})
+
+	patch, err := exec.Command("./ddiff", "-c=false", "-N", old, new).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	for _, s := range []string{
+		"--- /dev/null\n+++ added.txt\n@@ -0,0 +1 @@\n+hello\n",
+		"--- gone.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-bye\n-for now\n",
+		"+no newline\n\\ No newline at end of file\n",
+	} {
+		if !strings.Contains(string(patch), s) {
+			t.Errorf("expected the patch to contain\n%s\ngot\n%s", s, patch)
+		}
+	}
+
+	patchFile := filepath.Join(dir, "patch.diff")
+	if err := os.WriteFile(patchFile, patch, 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := exec.Command("./ddiff", "apply", patchFile, old).CombinedOutput()
+	if exitCode(err) != exitSame {
+		t.Fatalf("apply failed: %v\n%s", err, output)
+	}
+	if output, err := exec.Command("./ddiff", "-c=false", old, new).CombinedOutput(); err != nil {
+		t.Errorf("patched tree differs from the new tree: %v\n%s", err, output)
+	}
+}
+
+func TestCLIUnidirectionalNewFile(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeTree(t, a, map[string]string{"gone.txt": "bye\n"})
+	writeTree(t, b, map[string]string{"added.txt": "hello\n"})
+
+	output, err := exec.Command("./ddiff", "--format=normal", "--unidirectional-new-file", a, b).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	want := "diff " + os.DevNull + " " + filepath.Join(b, "added.txt") + "\n0a1\n> hello\nOnly in " + a + ": gone.txt\n"
+	if string(output) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, output)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import "sort"
+
+// histogramMaxChain is the number of occurrences above which a line is
+// considered too common to anchor a histogram diff, as in git.
+const histogramMaxChain = 64
+
+// patienceDiff anchors the diff on lines that occur exactly once in each
+// input, which keeps braces and blank lines from being paired across
+// unrelated blocks of refactored code. Regions without unique lines fall
+// back to Myers.
+func patienceDiff(lines1, lines2 []string) []Edit {
+	a, b := internLines(lines1, lines2)
+	d := newDiffer(a, b)
+	d.patience(0, len(a), 0, len(b))
+	return editsFromChanges(d.changed1, d.changed2)
+}
+
+// histogramDiff extends patience diff to lines that are merely rare rather
+// than unique: each region is split at the longest match containing the
+// least frequent line. This is the algorithm git uses for --histogram.
+func histogramDiff(lines1, lines2 []string) []Edit {
+	a, b := internLines(lines1, lines2)
+	d := newDiffer(a, b)
+	d.histogram(0, len(a), 0, len(b))
+	return editsFromChanges(d.changed1, d.changed2)
+}
+
+func (d *differ) patience(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi, done := d.trim(aLo, aHi, bLo, bHi)
+	if done {
+		return
+	}
+
+	type occurrence struct {
+		count1, count2 int
+		pos1           int
+	}
+	occurrences := make(map[int]*occurrence)
+	for i := aLo; i < aHi; i++ {
+		o := occurrences[d.a[i]]
+		if o == nil {
+			o = &occurrence{}
+			occurrences[d.a[i]] = o
+		}
+		o.count1++
+		o.pos1 = i
+	}
+	for j := bLo; j < bHi; j++ {
+		if o := occurrences[d.b[j]]; o != nil {
+			o.count2++
+		}
+	}
+
+	// Unique common lines in the order they appear in b.
+	var pairs [][2]int
+	for j := bLo; j < bHi; j++ {
+		if o := occurrences[d.b[j]]; o != nil && o.count1 == 1 && o.count2 == 1 {
+			pairs = append(pairs, [2]int{o.pos1, j})
+		}
+	}
+	anchors := longestIncreasing(pairs)
+	if len(anchors) == 0 {
+		d.compare(aLo, aHi, bLo, bHi)
+		return
+	}
+
+	i, j := aLo, bLo
+	for _, p := range anchors {
+		d.patience(i, p[0], j, p[1])
+		i, j = p[0]+1, p[1]+1
+	}
+	d.patience(i, aHi, j, bHi)
+}
+
+// longestIncreasing returns the longest subsequence of pairs, which are
+// ordered by their second element, whose first elements also increase. It
+// uses patience sorting, hence the name of the algorithm.
+func longestIncreasing(pairs [][2]int) [][2]int {
+	if len(pairs) == 0 {
+		return nil
+	}
+
+	// tails[k] is the index of the smallest pair ending an increasing run
+	// of length k+1; prev links each pair to its predecessor in that run.
+	var tails []int
+	prev := make([]int, len(pairs))
+	for idx, p := range pairs {
+		k := sort.Search(len(tails), func(k int) bool {
+			return pairs[tails[k]][0] >= p[0]
+		})
+		if k > 0 {
+			prev[idx] = tails[k-1]
+		} else {
+			prev[idx] = -1
+		}
+		if k == len(tails) {
+			tails = append(tails, idx)
+		} else {
+			tails[k] = idx
+		}
+	}
+
+	result := make([][2]int, len(tails))
+	for k, idx := len(tails)-1, tails[len(tails)-1]; k >= 0; k, idx = k-1, prev[idx] {
+		result[k] = pairs[idx]
+	}
+	return result
+}
+
+func (d *differ) histogram(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi, done := d.trim(aLo, aHi, bLo, bHi)
+	if done {
+		return
+	}
+
+	positions := make(map[int][]int)
+	for i := aLo; i < aHi; i++ {
+		positions[d.a[i]] = append(positions[d.a[i]], i)
+	}
+
+	bestLen, bestCount := 0, histogramMaxChain+1
+	bestA, bestB := 0, 0
+	for j := bLo; j < bHi; {
+		next := j + 1
+		candidates := positions[d.b[j]]
+		if len(candidates) == 0 || len(candidates) > min(bestCount, histogramMaxChain) {
+			j = next
+			continue
+		}
+		for _, i := range candidates {
+			count := len(candidates)
+			s1, s2 := i, j
+			for s1 > aLo && s2 > bLo && d.a[s1-1] == d.b[s2-1] {
+				s1--
+				s2--
+				count = min(count, len(positions[d.a[s1]]))
+			}
+			e1, e2 := i+1, j+1
+			for e1 < aHi && e2 < bHi && d.a[e1] == d.b[e2] {
+				count = min(count, len(positions[d.a[e1]]))
+				e1++
+				e2++
+			}
+			if e2 > next {
+				next = e2
+			}
+			if e1-s1 > bestLen || count < bestCount {
+				bestLen, bestCount = e1-s1, count
+				bestA, bestB = s1, s2
+			}
+		}
+		j = next
+	}
+
+	if bestLen == 0 {
+		// Nothing in common, or only lines too frequent to be useful
+		// anchors.
+		d.compare(aLo, aHi, bLo, bHi)
+		return
+	}
+
+	d.histogram(aLo, bestA, bLo, bestB)
+	d.histogram(bestA+bestLen, aHi, bestB+bestLen, bHi)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math/rand"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestDiffAlgorithmsProduceValidEdits(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	for iter := 0; iter < 300; iter++ {
+		lines1 := randomLines(r, r.Intn(60), 1+r.Intn(10))
+		lines2 := randomLines(r, r.Intn(60), 1+r.Intn(10))
+		for name, algorithm := range diffAlgorithms {
+			t.Run(name, func(t *testing.T) {
+				checkEdits(t, lines1, lines2, algorithm(lines1, lines2))
+			})
+		}
+	}
+}
+
+func TestPatienceDiffAnchorsOnUniqueLines(t *testing.T) {
+	lines1 := []string{
+		"void func1() {",
+		"    x += 1",
+		"}",
+		"",
+		"void func2() {",
+		"    x += 2",
+		"}",
+	}
+	lines2 := []string{
+		"void func1() {",
+		"    x += 1",
+		"}",
+		"",
+		"void functhreehalves() {",
+		"    x += 1.5",
+		"}",
+		"",
+		"void func2() {",
+		"    x += 2",
+		"}",
+	}
+
+	for _, name := range []string{"patience", "histogram"} {
+		edits := diffAlgorithms[name](lines1, lines2)
+		checkEdits(t, lines1, lines2, edits)
+
+		var inserted []string
+		for _, e := range edits {
+			if e.Type == "insert" {
+				inserted = append(inserted, lines2[e.Start2:e.End2]...)
+			}
+		}
+		if len(inserted) == 0 || inserted[0] != "void functhreehalves() {" {
+			t.Errorf("%s: expected insertion to start at the new function, got %q", name, inserted)
+		}
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	pairs := [][2]int{{3, 0}, {1, 1}, {4, 2}, {2, 3}, {5, 4}, {0, 5}}
+	got := longestIncreasing(pairs)
+	want := [][2]int{{1, 1}, {2, 3}, {5, 4}}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestCLIDiffAlgorithm(t *testing.T) {
+	for _, name := range []string{"lcs", "myers", "patience", "histogram"} {
+		cmd := exec.Command("./ddiff", "--color=false", "--diff-algorithm="+name, "testdata/file1.txt", "testdata/file2.txt")
+		output, err := cmd.CombinedOutput()
+		if exitCode(err) != exitDifferent {
+			t.Fatalf("%s: CLI command failed: %v\nOutput: %s", name, err, output)
+		}
+		if !strings.Contains(string(output), "+modified line 2") {
+			t.Errorf("%s: expected diff output, got %s", name, output)
+		}
+	}
+
+	cmd := exec.Command("./ddiff", "--diff-algorithm=bogus", "testdata/file1.txt", "testdata/file2.txt")
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Error("Expected CLI to fail with an unknown diff algorithm")
+	}
+	if !strings.Contains(string(output), "Unknown diff algorithm") {
+		t.Errorf("Expected unknown algorithm message, got %s", output)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultSimilarity is the threshold used when -M or --find-copies is given
+// without a percentage, as in git.
+const defaultSimilarity = 50
+
+// rename pairs a file that exists only in the new tree with the file in the
+// old tree it was moved or copied from.
+type rename struct {
+	from, to   string
+	similarity int // percent
+	copy       bool
+}
+
+// similarityFlag is a percentage flag that may also be given bare, like
+// git's -M and -C. Zero disables detection.
+type similarityFlag struct {
+	percent *int
+}
+
+func (f similarityFlag) String() string {
+	if f.percent == nil || *f.percent == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d%%", *f.percent)
+}
+
+func (f similarityFlag) Set(value string) error {
+	switch value {
+	case "true":
+		*f.percent = defaultSimilarity
+		return nil
+	case "false":
+		*f.percent = 0
+		return nil
+	}
+
+	percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid similarity %q: want a percentage from 0 to 100", value)
+	}
+	*f.percent = percent
+	return nil
+}
+
+func (f similarityFlag) IsBoolFlag() bool {
+	return true
+}
+
+// fileSignature summarizes a file's content for rename detection.
+type fileSignature struct {
+	hash   [sha256.Size]byte
+	binary bool
+	lines  int
+	counts map[string]int
+}
+
+func readSignature(path string) (*fileSignature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sig := &fileSignature{hash: sha256.Sum256(data)}
+	text := splitLines(string(data))
+	if isBinary(text.lines) {
+		sig.binary = true
+		return sig, nil
+	}
+
+	sig.lines = len(text.lines)
+	sig.counts = make(map[string]int)
+	for i, line := range text.lines {
+		sig.counts[line+text.eol(i)]++
+	}
+	return sig, nil
+}
+
+// similarity scores how much of the larger file's content also appears in
+// the other, as a percentage. Binary files only match exactly.
+func similarity(a, b *fileSignature) int {
+	if a.hash == b.hash {
+		return 100
+	}
+	if a.binary || b.binary {
+		return 0
+	}
+
+	common := 0
+	for line, n := range a.counts {
+		common += min(n, b.counts[line])
+	}
+	// Identical content was caught by the hash, so stop short of 100%.
+	return min(99, common*100/max(a.lines, b.lines))
+}
+
+// detectRenames pairs files added in dir2 with the files deleted from dir1
+// they were renamed from and, if copy detection is on, with any file in
+// dir1 they were copied from. Exact matches are found by hash first; the
+// remaining files are paired greedily by similarity score.
+func detectRenames(dir1, dir2 string, deleted, added, sources []string, config Config) []rename {
+	signatures := make(map[string]*fileSignature)
+	signature := func(dir, relPath string) *fileSignature {
+		path := filepath.Join(dir, relPath)
+		if sig, ok := signatures[path]; ok {
+			return sig
+		}
+		var sig *fileSignature
+		// Only regular files take part; unreadable ones are reported when
+		// they are compared.
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+			sig, _ = readSignature(path)
+		}
+		signatures[path] = sig
+		return sig
+	}
+
+	var renames []rename
+	matched := make(map[string]bool)
+
+	if config.findRenames > 0 {
+		renames = append(renames, pairBySimilarity(deleted, added, config.findRenames, false, matched,
+			func(relPath string) *fileSignature { return signature(dir1, relPath) },
+			func(relPath string) *fileSignature { return signature(dir2, relPath) })...)
+	}
+
+	if config.findCopies > 0 {
+		var remaining []string
+		for _, relPath := range added {
+			if !matched[relPath] {
+				remaining = append(remaining, relPath)
+			}
+		}
+		renames = append(renames, pairBySimilarity(sources, remaining, config.findCopies, true, matched,
+			func(relPath string) *fileSignature { return signature(dir1, relPath) },
+			func(relPath string) *fileSignature { return signature(dir2, relPath) })...)
+	}
+
+	return renames
+}
+
+// pairBySimilarity matches each target with at most one source scoring at
+// least threshold, best scores first. For renames each source is used
+// once; a copy source may be copied any number of times. Matched targets
+// are recorded in matched.
+func pairBySimilarity(sources, targets []string, threshold int, copies bool, matched map[string]bool,
+	sourceSig, targetSig func(string) *fileSignature) []rename {
+
+	type candidate struct {
+		source, target string
+		score          int
+	}
+	var candidates []candidate
+	for _, target := range targets {
+		tsig := targetSig(target)
+		if tsig == nil {
+			continue
+		}
+		for _, source := range sources {
+			ssig := sourceSig(source)
+			if ssig == nil {
+				continue
+			}
+			if score := similarity(ssig, tsig); score >= threshold {
+				candidates = append(candidates, candidate{source, target, score})
+			}
+		}
+	}
+
+	// Best score first; among equals, prefer a source with the same base
+	// name, then keep path order so the result is deterministic.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		aSame := filepath.Base(a.source) == filepath.Base(a.target)
+		bSame := filepath.Base(b.source) == filepath.Base(b.target)
+		if aSame != bSame {
+			return aSame
+		}
+		if a.target != b.target {
+			return a.target < b.target
+		}
+		return a.source < b.source
+	})
+
+	var renames []rename
+	used := make(map[string]bool)
+	for _, c := range candidates {
+		if matched[c.target] || (!copies && used[c.source]) {
+			continue
+		}
+		matched[c.target] = true
+		used[c.source] = true
+		renames = append(renames, rename{from: c.source, to: c.target, similarity: c.score, copy: copies})
+	}
+	return renames
+}
+
+// compareRenamed prints the extended header for a renamed or copied file
+// followed by the diff of its content against the original.
+func compareRenamed(dir1, dir2 string, r rename, config Config) (fileStat, error) {
+	// With --git the rename is part of the file's extended headers
+	if showPatch(config) && !structured(config) && !gitHeaderFormat(config) {
+		kind := "rename"
+		if r.copy {
+			kind = "copy"
+		}
+		printColor(config, "white", fmt.Sprintf("similarity index %d%%\n", r.similarity))
+		printColor(config, "white", fmt.Sprintf("%s from %s\n", kind, r.from))
+		printColor(config, "white", fmt.Sprintf("%s to %s\n", kind, r.to))
+	}
+
+	return diffFilePair(filepath.Join(dir1, r.from), filepath.Join(dir2, r.to), r.from, r.to, r.from+" => "+r.to, &r, config)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSimilarityFlag(t *testing.T) {
+	var percent int
+	f := similarityFlag{&percent}
+	for value, want := range map[string]int{"true": defaultSimilarity, "75%": 75, "30": 30, "false": 0} {
+		if err := f.Set(value); err != nil {
+			t.Fatalf("Set(%q) failed: %v", value, err)
+		}
+		if percent != want {
+			t.Errorf("Set(%q): expected %d, got %d", value, want, percent)
+		}
+	}
+	for _, value := range []string{"abc", "101%", "-5"} {
+		if err := f.Set(value); err == nil {
+			t.Errorf("Set(%q) should fail", value)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	sig := func(content string) *fileSignature {
+		path := filepath.Join(t.TempDir(), "f")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		s, err := readSignature(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if got := similarity(sig("a\nb\nc\nd\n"), sig("a\nb\nc\nd\n")); got != 100 {
+		t.Errorf("identical files: expected 100, got %d", got)
+	}
+	if got := similarity(sig("a\nb\nc\nd\n"), sig("a\nb\nc\nx\n")); got != 75 {
+		t.Errorf("one line of four changed: expected 75, got %d", got)
+	}
+	if got := similarity(sig("a\nb\n"), sig("a\nb\nc\nd\n")); got != 50 {
+		t.Errorf("file doubled in size: expected 50, got %d", got)
+	}
+	if got := similarity(sig("a\x00b"), sig("a\x00c")); got != 0 {
+		t.Errorf("different binary files: expected 0, got %d", got)
+	}
+}
+
+func TestDetectRenames(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeTree(t, dir1, map[string]string{
+		"old/exact.txt":   "1\n2\n3\n4\n",
+		"old/similar.txt": "a\nb\nc\nd\ne\n",
+		"gone.txt":        "p\nq\nr\n",
+		"kept.txt":        "k1\nk2\nk3\nk4\n",
+	})
+	writeTree(t, dir2, map[string]string{
+		"new/exact.txt":   "1\n2\n3\n4\n",
+		"new/similar.txt": "a\nb\nc\nd\nE\n",
+		"unrelated.txt":   "x\ny\nz\n",
+		"kept.txt":        "k1\nk2\nk3\nk4\n",
+		"copy.txt":        "k1\nk2\nk3\nk4\nk5\n",
+	})
+
+	deleted := []string{"gone.txt", "old/exact.txt", "old/similar.txt"}
+	added := []string{"copy.txt", "new/exact.txt", "new/similar.txt", "unrelated.txt"}
+	sources := []string{"gone.txt", "kept.txt", "old/exact.txt", "old/similar.txt"}
+
+	got := make(map[string]rename)
+	for _, r := range detectRenames(dir1, dir2, deleted, added, sources, Config{findRenames: 50, findCopies: 50}) {
+		got[r.to] = r
+	}
+
+	if r := got["new/exact.txt"]; r.from != "old/exact.txt" || r.similarity != 100 || r.copy {
+		t.Errorf("expected exact rename from old/exact.txt, got %+v", r)
+	}
+	if r := got["new/similar.txt"]; r.from != "old/similar.txt" || r.similarity != 80 || r.copy {
+		t.Errorf("expected 80%% rename from old/similar.txt, got %+v", r)
+	}
+	if r := got["copy.txt"]; r.from != "kept.txt" || !r.copy {
+		t.Errorf("expected copy from kept.txt, got %+v", r)
+	}
+	if r, ok := got["unrelated.txt"]; ok {
+		t.Errorf("unrelated file should not be paired, got %+v", r)
+	}
+
+	got = make(map[string]rename)
+	for _, r := range detectRenames(dir1, dir2, deleted, added, sources, Config{findRenames: 90}) {
+		got[r.to] = r
+	}
+	if _, ok := got["new/similar.txt"]; ok {
+		t.Error("80% similar file should not be paired with a 90% threshold")
+	}
+	if _, ok := got["copy.txt"]; ok {
+		t.Error("copies should not be detected without --find-copies")
+	}
+}
+
+func TestCLIFindRenames(t *testing.T) {
+	dir1, dir2 := t.TempDir(), t.TempDir()
+	writeTree(t, dir1, map[string]string{"src/util.go": "package util\n\nfunc A() {}\n\nfunc B() {}\n"})
+	writeTree(t, dir2, map[string]string{"lib/util.go": "package util\n\nfunc A() {}\n\nfunc C() {}\n"})
+
+	output, err := exec.Command("./ddiff", "--color=false", "-M", dir1, dir2).CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI rename detection failed: %v\nOutput: %s", err, output)
+	}
+	want := "similarity index 80%\nrename from src/util.go\nrename to lib/util.go\n--- src/util.go\n+++ lib/util.go\n"
+	if !strings.HasPrefix(string(output), want) {
+		t.Errorf("expected rename header\n%s\ngot\n%s", want, output)
+	}
+	if !strings.Contains(string(output), "-func B() {}\n+func C() {}\n") {
+		t.Errorf("expected only the real content change, got\n%s", output)
+	}
+
+	output, _ = exec.Command("./ddiff", "--color=false", dir1, dir2).CombinedOutput()
+	if strings.Contains(string(output), "rename from") {
+		t.Errorf("renames should only be detected with -M, got\n%s", output)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultWidth is the total output width of --side-by-side, as in GNU diff.
+const defaultWidth = 130
+
+// tabWidth is the distance between tab stops when tabs are expanded.
+const tabWidth = 8
+
+// sideRow is one line of side-by-side output. A line number of 0 leaves
+// that column empty.
+type sideRow struct {
+	num1, num2  int
+	left, right string
+	marker      byte // ' ' equal, '|' changed, '<' deleted, '>' inserted
+}
+
+// sideBySideRows lays out one group of edits, with its context, as rows.
+// Each run of deleted lines is paired with the inserted lines that follow
+// it; whichever side is longer continues alone.
+func sideBySideRows(text1, text2 textFile, edits []Edit, context int) []sideRow {
+	start1, end1, start2, _ := hunkBounds(len(text1.lines), len(text2.lines), edits, context)
+	var rows []sideRow
+	equal := func(from1, to1, from2 int) {
+		for i := from1; i < to1; i++ {
+			j := from2 + i - from1
+			rows = append(rows, sideRow{i + 1, j + 1, text1.lines[i], text2.lines[j], ' '})
+		}
+	}
+
+	equal(start1, edits[0].Start1, start2)
+	for k := 0; k < len(edits); k++ {
+		edit := edits[k]
+		switch edit.Type {
+		case "equal":
+			equal(edit.Start1, edit.End1, edit.Start2)
+		case "delete":
+			inserted := Edit{Start2: edit.End2, End2: edit.End2}
+			if k+1 < len(edits) && edits[k+1].Type == "insert" {
+				k++
+				inserted = edits[k]
+			}
+			deletes, inserts := edit.End1-edit.Start1, inserted.End2-inserted.Start2
+			for n := 0; n < max(deletes, inserts); n++ {
+				row := sideRow{marker: '|'}
+				if n < deletes {
+					row.num1, row.left = edit.Start1+n+1, text1.lines[edit.Start1+n]
+				} else {
+					row.marker = '>'
+				}
+				if n < inserts {
+					row.num2, row.right = inserted.Start2+n+1, text2.lines[inserted.Start2+n]
+				} else {
+					row.marker = '<'
+				}
+				rows = append(rows, row)
+			}
+		case "insert":
+			for j := edit.Start2; j < edit.End2; j++ {
+				rows = append(rows, sideRow{num2: j + 1, right: text2.lines[j], marker: '>'})
+			}
+		}
+	}
+	last := edits[len(edits)-1]
+	equal(last.End1, end1, last.End2)
+	return rows
+}
+
+// sideLayout holds the column widths for one file pair.
+type sideLayout struct {
+	numberWidth int
+	textWidth   int
+}
+
+// newSideLayout divides width between two columns of line numbers and text
+// separated by a three-character gutter.
+func newSideLayout(width, lines int) sideLayout {
+	numberWidth := len(strconv.Itoa(max(lines, 1)))
+	column := (width - 3) / 2
+	return sideLayout{numberWidth, max(1, column-numberWidth-1)}
+}
+
+// formatColumn renders one side of a row: the line number and the text
+// with tabs expanded, cut or padded to the text width.
+func (l sideLayout) formatColumn(num int, text string) string {
+	number := strings.Repeat(" ", l.numberWidth)
+	if num > 0 {
+		number = fmt.Sprintf("%*d", l.numberWidth, num)
+	}
+	text = fitColumn(expandTabs(text), l.textWidth)
+	return number + " " + text + strings.Repeat(" ", l.textWidth-utf8.RuneCountInString(text))
+}
+
+// expandTabs replaces each tab with the spaces that reach the next stop.
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	column := 0
+	for _, r := range s {
+		if r == '\t' {
+			spaces := tabWidth - column%tabWidth
+			b.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		}
+		b.WriteRune(r)
+		column++
+	}
+	return b.String()
+}
+
+// fitColumn cuts s to at most width characters, dropping control
+// characters such as a carriage return that would upset the layout.
+func fitColumn(s string, width int) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		if r < ' ' || r == 0x7f {
+			continue
+		}
+		if n == width {
+			break
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
+
+// printSideBySide prints the groups of a diff as two columns, old on the
+// left and new on the right, each hunk introduced by its unified header.
+func printSideBySide(file1, file2 string, text1, text2 textFile, groups [][]Edit, config Config) {
+	if len(groups) == 0 {
+		return
+	}
+	layout := newSideLayout(config.width, max(len(text1.lines), len(text2.lines)))
+
+	printColor(config, "white", fmt.Sprintf("--- %s\n", file1))
+	printColor(config, "white", fmt.Sprintf("+++ %s\n", file2))
+	for _, group := range groups {
+		start1, end1, start2, end2 := hunkBounds(len(text1.lines), len(text2.lines), group, config.showContext)
+		printColor(config, "cyan", fmt.Sprintf("@@ -%s +%s @@\n", hunkRange(start1, end1), hunkRange(start2, end2)))
+
+		for _, row := range sideBySideRows(text1, text2, group, config.showContext) {
+			left := layout.formatColumn(row.num1, row.left)
+			right := strings.TrimRight(layout.formatColumn(row.num2, row.right), " ")
+			switch row.marker {
+			case ' ':
+				fmt.Printf("%s   %s\n", left, right)
+			case '<':
+				printColor(config, "red", left)
+				printColor(config, "yellow", " <")
+				fmt.Println()
+			case '>':
+				fmt.Print(left)
+				printColor(config, "yellow", " > ")
+				printColor(config, "green", right)
+				fmt.Println()
+			default:
+				printColor(config, "red", left)
+				printColor(config, "yellow", " | ")
+				printColor(config, "green", right)
+				fmt.Println()
+			}
+		}
+	}
+}
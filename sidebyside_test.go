@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestExpandTabs(t *testing.T) {
+	cases := map[string]string{
+		"":           "",
+		"a":          "a",
+		"\tx":        "        x",
+		"ab\tx":      "ab      x",
+		"12345678\t": "12345678        ",
+		"é\tx":       "é       x",
+	}
+	for in, want := range cases {
+		if got := expandTabs(in); got != want {
+			t.Errorf("expandTabs(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFitColumn(t *testing.T) {
+	if got := fitColumn("abcdef", 4); got != "abcd" {
+		t.Errorf("expected truncation to 4, got %q", got)
+	}
+	if got := fitColumn("héllo", 10); got != "héllo" {
+		t.Errorf("expected short text unchanged, got %q", got)
+	}
+	if got := fitColumn("a\rb", 10); got != "ab" {
+		t.Errorf("expected control characters dropped, got %q", got)
+	}
+}
+
+func TestSideBySideRows(t *testing.T) {
+	text1 := textFile{lines: []string{"a", "b", "c", "d", "e"}}
+	text2 := textFile{lines: []string{"a", "B", "C", "X", "d"}}
+	groups := diffGroups(text1, text2, Config{showContext: 1})
+	if len(groups) != 1 {
+		t.Fatalf("expected one group, got %d", len(groups))
+	}
+
+	rows := sideBySideRows(text1, text2, groups[0], 1)
+	var got []string
+	for _, row := range rows {
+		got = append(got, strings.Join([]string{
+			string(row.marker), row.left, row.right,
+		}, ":"))
+	}
+	want := []string{" :a:a", "|:b:B", "|:c:C", ">::X", " :d:d", "<:e:"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected rows %q, got %q", want, got)
+	}
+	if rows[3].num1 != 0 || rows[3].num2 != 4 || rows[5].num1 != 5 || rows[5].num2 != 0 {
+		t.Errorf("unexpected line numbers in %+v", rows)
+	}
+}
+
+func TestCLISideBySide(t *testing.T) {
+	cmd := exec.Command("./ddiff", "--color=false", "-y", "--width=36", "testdata/file1.txt", "testdata/file2.txt")
+	output, err := cmd.CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, output)
+	}
+	want := strings.Join([]string{
+		"--- testdata/file1.txt",
+		"+++ testdata/file2.txt",
+		"@@ -1,5 +1,5 @@",
+		"1 line 1           1 line 1",
+		"2 line 2         | 2 modified line",
+		"3 line 3           3 line 3",
+		"4 line 4         | 4 new line 4",
+		"5 line 5           5 line 5",
+		"",
+	}, "\n")
+	if string(output) != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, output)
+	}
+
+	cmd = exec.Command("./ddiff", "-y", "--width=5", "testdata/file1.txt", "testdata/file2.txt")
+	if code := exitCode(cmd.Run()); code != exitTrouble {
+		t.Errorf("expected exit status 2 for a tiny width, got %d", code)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// fileStat records how many lines were inserted and deleted in one file.
+type fileStat struct {
+	path       string
+	insertions int
+	deletions  int
+	binary     bool
+	renamed    bool
+	differs    bool // found to differ without counting lines
+}
+
+func (s fileStat) changed() bool {
+	return s.binary || s.renamed || s.differs || s.insertions > 0 || s.deletions > 0
+}
+
+// showPatch reports whether the diff itself should be printed. The
+// machine-friendly summaries replace it, while --stats follows it.
+func showPatch(config Config) bool {
+	return !config.numStat && !config.shortStat && !listing(config)
+}
+
+// countChanges totals the inserted and deleted lines in the hunk groups.
+func countChanges(groups [][]Edit) (insertions, deletions int) {
+	for _, group := range groups {
+		for _, edit := range group {
+			switch edit.Type {
+			case "insert":
+				insertions += edit.End2 - edit.Start2
+			case "delete":
+				deletions += edit.End1 - edit.Start1
+			}
+		}
+	}
+	return insertions, deletions
+}
+
+// oneSidedStat counts a file that exists in only one directory as wholly
+// added or wholly deleted.
+func oneSidedStat(path, name string, added bool) fileStat {
+	stat := fileStat{path: name}
+	// Links and special files are not read through, but still count
+	if info, err := os.Lstat(path); err == nil && !info.Mode().IsRegular() {
+		stat.differs = true
+		return stat
+	}
+	lines, err := readFileLines(path)
+	if err != nil {
+		return stat
+	}
+	if isBinary(lines) {
+		stat.binary = true
+	} else if added {
+		stat.insertions = len(lines)
+	} else {
+		stat.deletions = len(lines)
+	}
+	return stat
+}
+
+// printStats prints whichever summaries config asks for, covering the
+// files in stats that changed.
+func printStats(stats []fileStat, config Config) {
+	if !config.showStats && !config.numStat && !config.shortStat {
+		return
+	}
+
+	var changed []fileStat
+	for _, stat := range stats {
+		if stat.changed() {
+			changed = append(changed, stat)
+		}
+	}
+
+	if config.numStat {
+		for _, stat := range changed {
+			if stat.binary {
+				fmt.Printf("-\t-\t%s\n", stat.path)
+			} else {
+				fmt.Printf("%d\t%d\t%s\n", stat.insertions, stat.deletions, stat.path)
+			}
+		}
+	}
+	// The diffstat goes with a text diff; as plain text it would break up a
+	// stream of JSON records, and an HTML page shows its own totals
+	if config.showStats && !structured(config) {
+		printDiffStat(changed, config)
+	} else if config.shortStat && len(changed) > 0 {
+		fmt.Println(statSummary(changed))
+	}
+}
+
+// printDiffStat prints git-style "path | N +++--" lines scaled to the
+// terminal width, followed by the totals line.
+func printDiffStat(stats []fileStat, config Config) {
+	if len(stats) == 0 {
+		return
+	}
+
+	nameWidth, maxChange := 0, 0
+	numberWidth := 0
+	for _, stat := range stats {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(stat.path))
+		maxChange = max(maxChange, stat.insertions+stat.deletions)
+		if stat.binary {
+			numberWidth = len("Bin")
+		}
+	}
+	numberWidth = max(numberWidth, len(strconv.Itoa(maxChange)))
+
+	// " name | count graph"
+	width := terminalWidth()
+	graphWidth := width - nameWidth - numberWidth - 5
+	if graphWidth < 10 {
+		nameWidth = max(10, nameWidth-(10-graphWidth))
+		graphWidth = max(1, width-nameWidth-numberWidth-5)
+	}
+
+	for _, stat := range stats {
+		name := truncateName(stat.path, nameWidth)
+		padding := strings.Repeat(" ", nameWidth-utf8.RuneCountInString(name))
+		if stat.binary {
+			fmt.Printf(" %s%s | %*s\n", name, padding, numberWidth, "Bin")
+			continue
+		}
+
+		plus, minus := graphBars(stat.insertions, stat.deletions, maxChange, graphWidth)
+
+		fmt.Printf(" %s%s | %*d", name, padding, numberWidth, stat.insertions+stat.deletions)
+		if plus+minus > 0 {
+			fmt.Print(" ")
+		}
+		printColor(config, "green", strings.Repeat("+", plus))
+		printColor(config, "red", strings.Repeat("-", minus))
+		fmt.Println()
+	}
+
+	fmt.Println(statSummary(stats))
+}
+
+// statSummary formats the totals line, leaving out whichever of insertions
+// and deletions is zero, as git does.
+func statSummary(stats []fileStat) string {
+	insertions, deletions := 0, 0
+	for _, stat := range stats {
+		insertions += stat.insertions
+		deletions += stat.deletions
+	}
+
+	summary := fmt.Sprintf(" %d %s changed", len(stats), plural(len(stats), "file", "files"))
+	if insertions > 0 || deletions == 0 {
+		summary += fmt.Sprintf(", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+	if deletions > 0 || insertions == 0 {
+		summary += fmt.Sprintf(", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+	return summary
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// graphBars returns how many "+" and "-" signs show a file's insertions and
+// deletions in a graph of the given width, where maxChange is the largest
+// change of any file. Deletions always keep at least one sign, so that a
+// file with any is never shown as only gaining lines.
+func graphBars(insertions, deletions, maxChange, width int) (plus, minus int) {
+	if maxChange <= width {
+		return insertions, deletions
+	}
+	plus = scaleChange(insertions, maxChange, width)
+	minus = scaleChange(deletions, maxChange, width)
+	if plus+minus > width {
+		if plus > minus || minus == 1 {
+			plus--
+		} else {
+			minus--
+		}
+	}
+	return plus, minus
+}
+
+// scaleChange scales n from the range [0, maxChange] to [0, width], keeping
+// any non-zero count visible.
+func scaleChange(n, maxChange, width int) int {
+	if n == 0 {
+		return 0
+	}
+	return 1 + n*(width-1)/maxChange
+}
+
+// truncateName shortens a path that is too wide for the stat column by
+// replacing its beginning with "...".
+func truncateName(name string, width int) string {
+	runes := []rune(name)
+	if len(runes) <= width {
+		return name
+	}
+	if width <= 3 {
+		return string(runes[len(runes)-width:])
+	}
+	return "..." + string(runes[len(runes)-width+3:])
+}
+
+// terminalWidth returns the width to fit the stat graph into: $COLUMNS
+// when set, otherwise the width of the terminal on standard output, or 80
+// when that is not a terminal.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if columns := ttyWidth(); columns > 0 {
+		return columns
+	}
+	return 80
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCountChanges(t *testing.T) {
+	lines1 := []string{"a", "b", "c", "d"}
+	lines2 := []string{"a", "B", "c", "d", "e", "f"}
+
+	insertions, deletions := countChanges(diffGroups(textFile{lines: lines1}, textFile{lines: lines2}, Config{showContext: 3}))
+	if insertions != 3 || deletions != 1 {
+		t.Errorf("expected 3 insertions and 1 deletion, got %d and %d", insertions, deletions)
+	}
+}
+
+func TestStatSummary(t *testing.T) {
+	cases := []struct {
+		stats []fileStat
+		want  string
+	}{
+		{[]fileStat{{insertions: 1, deletions: 1}}, " 1 file changed, 1 insertion(+), 1 deletion(-)"},
+		{[]fileStat{{insertions: 2}, {insertions: 3}}, " 2 files changed, 5 insertions(+)"},
+		{[]fileStat{{deletions: 4}}, " 1 file changed, 4 deletions(-)"},
+		{[]fileStat{{binary: true}}, " 1 file changed, 0 insertions(+), 0 deletions(-)"},
+	}
+	for _, c := range cases {
+		if got := statSummary(c.stats); got != c.want {
+			t.Errorf("expected %q, got %q", c.want, got)
+		}
+	}
+}
+
+func TestScaleChange(t *testing.T) {
+	if got := scaleChange(0, 100, 10); got != 0 {
+		t.Errorf("zero changes should scale to 0, got %d", got)
+	}
+	if got := scaleChange(1, 1000, 10); got != 1 {
+		t.Errorf("a single change should stay visible, got %d", got)
+	}
+	if got := scaleChange(1000, 1000, 10); got != 10 {
+		t.Errorf("the largest change should fill the graph, got %d", got)
+	}
+}
+
+func TestGraphBars(t *testing.T) {
+	cases := []struct {
+		insertions, deletions, maxChange, width int
+		plus, minus                             int
+	}{
+		{3, 2, 5, 10, 3, 2},
+		{50, 50, 100, 10, 5, 5},
+		{90, 10, 100, 10, 9, 1},
+		{1, 1, 2, 1, 0, 1}, // deletions keep their sign in a 1-column graph
+		{5, 0, 5, 1, 1, 0},
+	}
+	for _, c := range cases {
+		plus, minus := graphBars(c.insertions, c.deletions, c.maxChange, c.width)
+		if plus != c.plus || minus != c.minus {
+			t.Errorf("graphBars(%d, %d, %d, %d) = %d, %d; want %d, %d",
+				c.insertions, c.deletions, c.maxChange, c.width, plus, minus, c.plus, c.minus)
+		}
+	}
+}
+
+func TestTerminalWidth(t *testing.T) {
+	t.Setenv("COLUMNS", "132")
+	if got := terminalWidth(); got != 132 {
+		t.Errorf("expected $COLUMNS to set the width, got %d", got)
+	}
+	// Under go test, standard output is normally not a terminal
+	t.Setenv("COLUMNS", "")
+	want := 80
+	if columns := ttyWidth(); columns > 0 {
+		want = columns
+	}
+	if got := terminalWidth(); got != want {
+		t.Errorf("expected width %d, got %d", want, got)
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	if got := truncateName("src/models/user.go", 30); got != "src/models/user.go" {
+		t.Errorf("short name should be unchanged, got %q", got)
+	}
+	if got := truncateName("src/models/user.go", 10); got != "...user.go" {
+		t.Errorf("expected %q, got %q", "...user.go", got)
+	}
+}
+
+func TestCLIStats(t *testing.T) {
+	cmd := exec.Command("./ddiff", "--color=false", "--stats", "testdata/deep1", "testdata/deep2")
+	output, err := cmd.CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI stats failed: %v\nOutput: %s", err, output)
+	}
+	outputStr := string(output)
+	if !strings.Contains(outputStr, "@@") {
+		t.Error("--stats should still print the diff")
+	}
+	if !strings.Contains(outputStr, " src/utils/helpers.go") || !strings.Contains(outputStr, "| 11 +++++++++++") {
+		t.Errorf("expected a diffstat line for src/utils/helpers.go, got:\n%s", outputStr)
+	}
+	if !strings.Contains(outputStr, "7 files changed, 53 insertions(+), 16 deletions(-)") {
+		t.Errorf("expected totals line, got:\n%s", outputStr)
+	}
+}
+
+func TestCLINumstatAndShortstat(t *testing.T) {
+	cmd := exec.Command("./ddiff", "--numstat", "testdata/deep1", "testdata/deep2")
+	output, err := cmd.CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI numstat failed: %v\nOutput: %s", err, output)
+	}
+	if strings.Contains(string(output), "@@") {
+		t.Error("--numstat should replace the diff")
+	}
+	if !strings.Contains(string(output), "9\t4\tsrc/models/user.go\n") {
+		t.Errorf("expected numstat line for src/models/user.go, got:\n%s", output)
+	}
+
+	cmd = exec.Command("./ddiff", "--shortstat", "testdata/deep1", "testdata/deep2")
+	output, err = cmd.CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI shortstat failed: %v\nOutput: %s", err, output)
+	}
+	if got := string(output); got != " 7 files changed, 53 insertions(+), 16 deletions(-)\n" {
+		t.Errorf("unexpected shortstat output %q", got)
+	}
+}
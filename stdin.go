@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"strings"
+	"sync"
+)
+
+// stdinName is the file name that stands for standard input.
+const stdinName = "-"
+
+// stdinText is standard input once it has been read. It can only be read
+// once, but may be named as both files or needed again for a header.
+var stdinText struct {
+	once sync.Once
+	text textFile
+	err  error
+}
+
+// readStdin reads standard input the first time it is called and returns
+// the same content every time after that.
+func readStdin() (textFile, error) {
+	stdinText.once.Do(func() {
+		stdinText.text, stdinText.err = readText(os.Stdin)
+	})
+	return stdinText.text, stdinText.err
+}
+
+// statInput is os.Stat, except that "-" is standard input.
+func statInput(path string) (fs.FileInfo, error) {
+	if path == stdinName {
+		return os.Stdin.Stat()
+	}
+	return os.Stat(path)
+}
+
+// String returns the content of text exactly as it was read.
+func (text textFile) String() string {
+	var b strings.Builder
+	for i, line := range text.lines {
+		b.WriteString(line)
+		b.WriteString(text.eol(i))
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTextFileString(t *testing.T) {
+	for _, data := range []string{"", "a\nb\n", "a\r\nb", "a\rb\n\n"} {
+		if got := splitLines(data).String(); got != data {
+			t.Errorf("expected %q, got %q", data, got)
+		}
+	}
+}
+
+func TestCLIStdin(t *testing.T) {
+	old, err := os.ReadFile("testdata/file1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("./ddiff", "-c=false", "-", "testdata/file2.txt")
+	cmd.Stdin = strings.NewReader(string(old))
+	output, err := cmd.Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	want, err := exec.Command("./ddiff", "-c=false", "testdata/file1.txt", "testdata/file2.txt").Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	want = []byte(strings.Replace(string(want), "--- testdata/file1.txt", "--- -", 1))
+	if string(output) != string(want) {
+		t.Errorf("expected\n%s\ngot\n%s", want, output)
+	}
+
+	// Standard input can be named as both files, and read only once
+	cmd = exec.Command("./ddiff", "-", "-")
+	cmd.Stdin = strings.NewReader("same\n")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Errorf("expected standard input to equal itself, got %v\n%s", err, output)
+	}
+
+	// git's blob hash is taken from the content read
+	cmd = exec.Command("./ddiff", "-c=false", "--git", "testdata/file1.txt", "-")
+	cmd.Stdin = strings.NewReader("hello\n")
+	output, _ = cmd.Output()
+	if !strings.Contains(string(output), "..ce01362 100644\n") {
+		t.Errorf("expected the hash of standard input, got\n%s", output)
+	}
+}
+
+func TestCLIFileAgainstDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"src/main.c": "int x;\n", "main.c": "int y;\n"})
+	file, src := filepath.Join(dir, "main.c"), filepath.Join(dir, "src")
+
+	output, err := exec.Command("./ddiff", "-c=false", file, src).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if want := "--- " + file + "\n+++ " + filepath.Join(src, "main.c") + "\n"; !strings.HasPrefix(string(output), want) {
+		t.Errorf("expected output to start with\n%s\ngot\n%s", want, output)
+	}
+
+	output, err = exec.Command("./ddiff", "-c=false", src, file).Output()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v", err)
+	}
+	if want := "--- " + filepath.Join(src, "main.c") + "\n+++ " + file + "\n"; !strings.HasPrefix(string(output), want) {
+		t.Errorf("expected output to start with\n%s\ngot\n%s", want, output)
+	}
+
+	cmd := exec.Command("./ddiff", "-", src)
+	cmd.Stdin = strings.NewReader("int x;\n")
+	if output, err := cmd.CombinedOutput(); exitCode(err) != exitTrouble {
+		t.Errorf("expected standard input against a directory to fail, got %v\n%s", err, output)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+// ttyWidth returns 0 where the terminal size cannot be queried, leaving the
+// width to $COLUMNS or the default.
+func ttyWidth() int {
+	return 0
+}
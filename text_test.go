@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitLinesKeepsTerminators(t *testing.T) {
+	cases := []struct {
+		data  string
+		lines []string
+		eols  []string
+	}{
+		{"", nil, []string{}},
+		{"a\n", []string{"a"}, []string{"\n"}},
+		{"a", []string{"a"}, []string{""}},
+		{"a\r\nb\rc\nd", []string{"a", "b\rc", "d"}, []string{"\r\n", "\n", ""}},
+		{"\n\n", []string{"", ""}, []string{"\n", "\n"}},
+		{"a\r", []string{"a\r"}, []string{""}},
+	}
+	for _, c := range cases {
+		text := splitLines(c.data)
+		if strings.Join(text.lines, "|") != strings.Join(c.lines, "|") || len(text.lines) != len(c.lines) {
+			t.Errorf("%q: expected lines %q, got %q", c.data, c.lines, text.lines)
+		}
+		if strings.Join(text.eols, "|") != strings.Join(c.eols, "|") || len(text.eols) != len(c.eols) {
+			t.Errorf("%q: expected terminators %q, got %q", c.data, c.eols, text.eols)
+		}
+
+		var rebuilt strings.Builder
+		for i, line := range text.lines {
+			rebuilt.WriteString(line + text.eol(i))
+		}
+		if rebuilt.String() != c.data {
+			t.Errorf("%q: lines and terminators rebuild %q", c.data, rebuilt.String())
+		}
+	}
+}
+
+func TestReadTextFileLongLines(t *testing.T) {
+	// bufio.Scanner gives up on lines over 64 KiB.
+	long := strings.Repeat("x", 1<<20)
+	path := filepath.Join(t.TempDir(), "long.json")
+	if err := os.WriteFile(path, []byte("{\n"+long+"\n}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := readTextFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file with a long line: %v", err)
+	}
+	if len(text.lines) != 3 || text.lines[1] != long {
+		t.Fatalf("expected the long line intact, got %d lines", len(text.lines))
+	}
+	if !text.missingNewline(2) {
+		t.Error("expected the last line to be reported as unterminated")
+	}
+}
+
+func TestLineEndingChanges(t *testing.T) {
+	crlf := splitLines("a\r\nb\r\n")
+	lf := splitLines("a\nb\n")
+
+	groups := diffGroups(crlf, lf, Config{showContext: 3})
+	diff := strings.Join(formatUnifiedDiff("x", "y", crlf, lf, groups, 3), "\n")
+	if !strings.Contains(diff, "-a\r\n-b\r\n+a\n+b") {
+		t.Errorf("expected CRLF to LF change to be shown, got %q", diff)
+	}
+
+	if groups := diffGroups(crlf, lf, Config{showContext: 3, ignoreCRAtEOL: true}); len(groups) != 0 {
+		t.Errorf("expected no changes with --ignore-cr-at-eol, got %v", groups)
+	}
+}
+
+func TestCLILongLines(t *testing.T) {
+	dir := t.TempDir()
+	long := strings.Repeat("y", 200000)
+	file1 := filepath.Join(dir, "a.min.js")
+	file2 := filepath.Join(dir, "b.min.js")
+	if err := os.WriteFile(file1, []byte(long+"1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file2, []byte(long+"2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("./ddiff", "--color=false", file1, file2).CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI comparison of long lines failed: %v\nOutput: %.200s", err, output)
+	}
+	if !strings.Contains(string(output), "+"+long+"2\n") {
+		t.Error("expected the long line in the diff output")
+	}
+}
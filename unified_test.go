@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// roundTripCase is a pair of file contents whose unified diff must turn
+// the first into the second when applied.
+type roundTripCase struct {
+	name     string
+	old, new string
+}
+
+func roundTripCorpus() []roundTripCase {
+	cases := []roundTripCase{
+		{"single change", "a\nb\nc\n", "a\nB\nc\n"},
+		{"empty to content", "", "a\nb\n"},
+		{"content to empty", "a\nb\n", ""},
+		{"add final newline", "a\nb", "a\nb\n"},
+		{"remove final newline", "a\nb\n", "a\nb"},
+		{"change unterminated last line", "a\nb", "a\nc"},
+		{"change before unterminated last line", "a\nb\nc", "A\nb\nc"},
+		{"unterminated single line to empty", "a", ""},
+		{"insert at start", "b\nc\n", "a\nb\nc\n"},
+		{"append at end", "a\nb\n", "a\nb\nc\n"},
+		{"gap of exactly twice the context", "x\n1\n2\n3\n4\n5\n6\nx\n", "y\n1\n2\n3\n4\n5\n6\ny\n"},
+		{"gap wider than twice the context", "x\n1\n2\n3\n4\n5\n6\n7\nx\n", "y\n1\n2\n3\n4\n5\n6\n7\ny\n"},
+		{"gap narrower than twice the context", "x\n1\n2\n3\nx\n", "y\n1\n2\n3\ny\n"},
+		{"short file tail", "a\nb\nc\nd\n", "a\nb\nc\nD\ne\n"},
+		{"repeated lines", "a\na\na\nb\na\na\n", "a\nb\na\nb\na\n"},
+		{"LF to CRLF", "a\nb\nc\n", "a\r\nb\r\nc\r\n"},
+		{"CRLF to LF", "a\r\nb\r\nc\r\n", "a\nb\nc\n"},
+		{"change in CRLF file", "a\r\nb\r\nc\r\n", "a\r\nB\r\nc\r\n"},
+		{"mixed endings", "a\r\nb\nc", "a\nb\r\nc\r\n"},
+		{"unterminated CRLF file", "a\r\nb", "a\r\nb\r\n"},
+		{"change after a lone CR", "a\rb\nc\n", "a\rb\nC\n"},
+		{"change around a lone CR", "a\rb\nc\n", "a\rB\nc\n"},
+		{"unterminated line ending in CR", "a\nb\r", "a\nc\r"},
+	}
+
+	// Random edits of files built from a small alphabet produce many
+	// adjacent and overlapping-context hunks.
+	r := rand.New(rand.NewSource(6))
+	for i := 0; i < 60; i++ {
+		cases = append(cases, roundTripCase{
+			name: fmt.Sprintf("random %d", i),
+			old:  randomContent(r),
+			new:  randomContent(r),
+		})
+	}
+	return cases
+}
+
+func randomContent(r *rand.Rand) string {
+	lines := randomLines(r, r.Intn(30), 1+r.Intn(5))
+	if len(lines) == 0 {
+		return ""
+	}
+	content := strings.Join(lines, "\n")
+	if r.Intn(4) != 0 {
+		content += "\n"
+	}
+	return content
+}
+
+// writeRoundTripFiles writes old/f and new/f under dir and returns the
+// unified diff ddiff produces for them with the given context.
+func writeRoundTripFiles(t *testing.T, dir string, c roundTripCase, context int) string {
+	t.Helper()
+	for _, side := range []struct{ name, content string }{{"old", c.old}, {"new", c.new}} {
+		if err := os.MkdirAll(filepath.Join(dir, side.name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, side.name, "f"), []byte(side.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	binary, err := filepath.Abs("ddiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(binary, "--color=false", fmt.Sprintf("-C=%d", context), "old/f", "new/f")
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	if code := exitCode(err); code == exitTrouble || code < 0 {
+		t.Fatalf("%s: ddiff failed: %v", c.name, err)
+	}
+	if (c.old == c.new) != (len(output) == 0) {
+		t.Fatalf("%s: unexpected diff output %q", c.name, output)
+	}
+	return string(output)
+}
+
+func TestUnifiedDiffRoundTripsThroughPatch(t *testing.T) {
+	if _, err := exec.LookPath("patch"); err != nil {
+		t.Skip("patch not installed")
+	}
+
+	for _, context := range []int{0, 1, 3} {
+		for _, c := range roundTripCorpus() {
+			dir := t.TempDir()
+			diff := writeRoundTripFiles(t, dir, c, context)
+			if diff == "" {
+				continue
+			}
+
+			cmd := exec.Command("patch", "-p0", "--batch", "--silent", "old/f")
+			cmd.Dir = dir
+			cmd.Stdin = strings.NewReader(diff)
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%s (context %d): patch failed: %v\n%s\ndiff:\n%s", c.name, context, err, output, diff)
+			}
+
+			got, err := os.ReadFile(filepath.Join(dir, "old", "f"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.new {
+				t.Errorf("%s (context %d): patched file is %q, want %q\ndiff:\n%s", c.name, context, got, c.new, diff)
+			}
+		}
+	}
+}
+
+func TestUnifiedDiffRoundTripsThroughGitApply(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	for _, context := range []int{1, 3} {
+		for _, c := range roundTripCorpus() {
+			dir := t.TempDir()
+			diff := writeRoundTripFiles(t, dir, c, context)
+			if diff == "" {
+				continue
+			}
+
+			// -p1 strips the old/ and new/ prefixes so both name f.
+			work := filepath.Join(dir, "work")
+			if err := os.MkdirAll(work, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(work, "f"), []byte(c.old), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cmd := exec.Command("git", "apply", "-p1", "-")
+			cmd.Dir = work
+			cmd.Env = append(os.Environ(), "GIT_CEILING_DIRECTORIES="+dir)
+			cmd.Stdin = strings.NewReader(diff)
+			if output, err := cmd.CombinedOutput(); err != nil {
+				t.Fatalf("%s (context %d): git apply failed: %v\n%s\ndiff:\n%s", c.name, context, err, output, diff)
+			}
+
+			got, err := os.ReadFile(filepath.Join(work, "f"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != c.new {
+				t.Errorf("%s (context %d): applied file is %q, want %q\ndiff:\n%s", c.name, context, got, c.new, diff)
+			}
+		}
+	}
+}
+
+func TestHunkHeaders(t *testing.T) {
+	cases := []struct {
+		old, new []string
+		want     string
+	}{
+		{nil, []string{"a", "b"}, "@@ -0,0 +1,2 @@"},
+		{[]string{"a", "b"}, nil, "@@ -1,2 +0,0 @@"},
+		{[]string{"a"}, []string{"b"}, "@@ -1 +1 @@"},
+		{[]string{"a", "b", "c", "d", "e"}, []string{"a", "b", "x", "c", "d", "e"}, "@@ -2,0 +3 @@"},
+	}
+	for _, c := range cases {
+		diff := generateUnifiedDiff("a", "b", c.old, c.new, Config{showContext: 0})
+		if len(diff) < 3 || diff[2] != c.want {
+			t.Errorf("%q -> %q: expected header %q, got %q", c.old, c.new, c.want, diff)
+		}
+	}
+}
+
+func TestHunksDoNotOverlap(t *testing.T) {
+	// Changes four lines apart with two lines of context must be merged
+	// into one hunk rather than emitted as two hunks sharing lines.
+	lines1 := []string{"x", "1", "2", "3", "4", "x"}
+	lines2 := []string{"y", "1", "2", "3", "4", "y"}
+	hunks := createHunksFromEdits(lines1, lines2, computeDiff(lines1, lines2), 2)
+	if len(hunks) != 1 {
+		t.Fatalf("expected a single merged hunk, got %d: %q", len(hunks), hunks)
+	}
+	if hunks[0][0] != "@@ -1,6 +1,6 @@" {
+		t.Errorf("unexpected hunk header %q", hunks[0][0])
+	}
+
+	lines1 = append([]string{"x"}, append(strings.Split("1 2 3 4 5", " "), "x")...)
+	lines2 = append([]string{"y"}, append(strings.Split("1 2 3 4 5", " "), "y")...)
+	hunks = createHunksFromEdits(lines1, lines2, computeDiff(lines1, lines2), 2)
+	if len(hunks) != 2 {
+		t.Fatalf("expected two hunks, got %d: %q", len(hunks), hunks)
+	}
+	if hunks[0][0] != "@@ -1,3 +1,3 @@" || hunks[1][0] != "@@ -5,3 +5,3 @@" {
+		t.Errorf("unexpected hunk headers %q and %q", hunks[0][0], hunks[1][0])
+	}
+}
+
+func TestNoNewlineMarker(t *testing.T) {
+	text, err := readTextFile("testdata/file1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !text.missingNewline(len(text.lines) - 1) {
+		t.Fatal("testdata/file1.txt should be detected as lacking a final newline")
+	}
+
+	terminated := textFile{lines: []string{"a", "b"}}
+	unterminated := textFile{lines: []string{"a", "b"}, eols: []string{"\n", ""}}
+	config := Config{showContext: 3}
+	diff := formatUnifiedDiff("x", "y", terminated, unterminated, diffGroups(terminated, unterminated, config), 3)
+	want := []string{"--- x", "+++ y", "@@ -1,2 +1,2 @@", " a", "-b", "+b", "\\ No newline at end of file"}
+	if strings.Join(diff, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(diff, "\n"))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ignoresWhitespace reports whether any option that changes how lines are
+// compared is set.
+func ignoresWhitespace(config Config) bool {
+	return config.ignoreSpace || config.ignoreSpaceChange ||
+		config.ignoreTrailingSpace || config.ignoreCRAtEOL
+}
+
+// normalizeLine applies the whitespace options in config to a single line.
+func normalizeLine(line string, config Config) string {
+	if config.ignoreCRAtEOL {
+		line = strings.TrimSuffix(line, "\r")
+	}
+
+	switch {
+	case config.ignoreSpace:
+		// Whitespace anywhere in the line is insignificant.
+		line = strings.Map(func(r rune) rune {
+			if unicode.IsSpace(r) {
+				return -1
+			}
+			return r
+		}, line)
+	case config.ignoreSpaceChange:
+		// Any run of whitespace matches any other, and trailing
+		// whitespace matches none.
+		var b strings.Builder
+		inSpace := false
+		for _, r := range line {
+			if unicode.IsSpace(r) {
+				inSpace = true
+				continue
+			}
+			if inSpace {
+				b.WriteByte(' ')
+				inSpace = false
+			}
+			b.WriteRune(r)
+		}
+		line = b.String()
+	case config.ignoreTrailingSpace:
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+	}
+
+	return line
+}
+
+// isBlankLine reports whether line counts as blank for --ignore-blank-lines.
+// Lines containing only whitespace are blank when whitespace is otherwise
+// being ignored.
+func isBlankLine(line string, config Config) bool {
+	return normalizeLine(line, config) == ""
+}
+
+// onlyBlankChanges reports whether every line deleted or inserted by edits
+// is blank, so that a hunk made of them can be suppressed.
+func onlyBlankChanges(lines1, lines2 []string, edits []Edit, config Config) bool {
+	for _, edit := range edits {
+		switch edit.Type {
+		case "delete":
+			for i := edit.Start1; i < edit.End1; i++ {
+				if !isBlankLine(lines1[i], config) {
+					return false
+				}
+			}
+		case "insert":
+			for i := edit.Start2; i < edit.End2; i++ {
+				if !isBlankLine(lines2[i], config) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeLine(t *testing.T) {
+	cases := []struct {
+		name   string
+		config Config
+		a, b   string
+		equal  bool
+	}{
+		{"exact", Config{}, "a b", "a  b", false},
+		{"all space", Config{ignoreSpace: true}, "a b", "ab", true},
+		{"all space tabs", Config{ignoreSpace: true}, "\tif (x)", "if(x)  ", true},
+		{"space change", Config{ignoreSpaceChange: true}, "a \t b  ", "a b", true},
+		{"space change keeps presence", Config{ignoreSpaceChange: true}, "a b", "ab", false},
+		{"space change leading", Config{ignoreSpaceChange: true}, "  x", "\tx", true},
+		{"trailing", Config{ignoreTrailingSpace: true}, "x  \t", "x", true},
+		{"trailing keeps inner", Config{ignoreTrailingSpace: true}, "a  b", "a b", false},
+		{"cr at eol", Config{ignoreCRAtEOL: true}, "x\r", "x", true},
+		{"cr kept", Config{}, "x\r", "x", false},
+	}
+	for _, c := range cases {
+		got := normalizeLine(c.a, c.config) == normalizeLine(c.b, c.config)
+		if got != c.equal {
+			t.Errorf("%s: %q vs %q: expected equal=%v", c.name, c.a, c.b, c.equal)
+		}
+	}
+}
+
+func TestIgnoreSpaceKeepsOriginalText(t *testing.T) {
+	lines1 := []string{"func f() {", "  return 1", "}"}
+	lines2 := []string{"func f() {", "\treturn 1", "}", "// end"}
+
+	diff := strings.Join(generateUnifiedDiff("a", "b", lines1, lines2, Config{showContext: 3, ignoreSpace: true}), "\n")
+	if strings.Contains(diff, "-  return 1") || strings.Contains(diff, "+\treturn 1") {
+		t.Errorf("whitespace-only change should not be reported:\n%s", diff)
+	}
+	if !strings.Contains(diff, "+// end") {
+		t.Errorf("real change should still be reported:\n%s", diff)
+	}
+	if !strings.Contains(diff, "   return 1") {
+		t.Errorf("context should show the original line text:\n%s", diff)
+	}
+}
+
+func TestIgnoreBlankLines(t *testing.T) {
+	lines1 := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	lines2 := []string{"a", "", "b", "c", "d", "e", "f", "g", "h", "i", "J"}
+
+	config := Config{showContext: 1, ignoreBlankLines: true}
+	diff := strings.Join(generateUnifiedDiff("a", "b", lines1, lines2, config), "\n")
+	if strings.Contains(diff, "@@ -1") {
+		t.Errorf("hunk with only a blank line insertion should be suppressed:\n%s", diff)
+	}
+	if !strings.Contains(diff, "+J") {
+		t.Errorf("non-blank change should be reported:\n%s", diff)
+	}
+
+	diff = strings.Join(generateUnifiedDiff("a", "b", lines1[:3], []string{"a", "", "b", "c"}, config), "\n")
+	if diff != "" {
+		t.Errorf("expected no output for blank-only change, got:\n%s", diff)
+	}
+}
+
+func TestCLIIgnoreSpace(t *testing.T) {
+	cmd := exec.Command("./ddiff", "--color=false", "-w", "testdata/file1.txt", "testdata/file2.txt")
+	output, err := cmd.CombinedOutput()
+	if exitCode(err) != exitDifferent {
+		t.Fatalf("CLI command failed: %v\nOutput: %s", err, output)
+	}
+	if !strings.Contains(string(output), "+modified line 2") {
+		t.Errorf("-w should still report real changes, got %s", output)
+	}
+}